@@ -0,0 +1,216 @@
+package arith
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the number of times a variable's value may refer to another variable
+const MAX_RECURSION = 64
+
+/* The shell variables that an expression reads and assigns */
+type Variables interface {
+	Get(name string) (string, bool)
+	Set(name string, value string) error
+}
+
+type evaluator struct {
+	vars  Variables
+	depth int
+}
+
+/* Evaluate the expression. A variable that is unset or null evaluates to
+ * zero. Otherwise, the variable's value is evaluated as an expression. */
+func Eval(node Node, vars Variables) (int64, error) {
+	e := &evaluator{vars: vars}
+	return e.eval(node)
+}
+
+func (e *evaluator) eval(node Node) (int64, error) {
+	switch n := node.(type) {
+	case *NumberLiteral:
+		return n.Value, nil
+	case *Variable:
+		return e.lookup(n.Name)
+	case *UnaryOp:
+		return e.evalUnary(n)
+	case *BinaryOp:
+		return e.evalBinary(n)
+	case *Assignment:
+		return e.evalAssignment(n)
+	case *IncDec:
+		return e.evalIncDec(n)
+	case *Conditional:
+		cond, err := e.eval(n.Cond)
+		if err != nil {
+			return 0, err
+		} else if cond != 0 {
+			return e.eval(n.Then)
+		}
+		return e.eval(n.Else)
+	}
+	return 0, fmt.Errorf("Unhandled arithmetic node %v", node)
+}
+
+func (e *evaluator) lookup(name string) (int64, error) {
+	text, ok := e.vars.Get(name)
+	if !ok || strings.TrimSpace(text) == "" {
+		return 0, nil
+	}
+
+	if e.depth >= MAX_RECURSION {
+		return 0, fmt.Errorf("%v: expression recursion level exceeded", name)
+	}
+
+	node, err := Parse(text)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %v", text, err)
+	}
+
+	e.depth++
+	defer func() { e.depth-- }()
+	return e.eval(node)
+}
+
+func (e *evaluator) assign(name string, value int64) error {
+	return e.vars.Set(name, strconv.FormatInt(value, 10))
+}
+
+func (e *evaluator) evalUnary(n *UnaryOp) (int64, error) {
+	value, err := e.eval(n.Operand)
+	if err != nil {
+		return 0, err
+	}
+	switch n.Operator {
+	case "!":
+		return boolToInt(value == 0), nil
+	case "~":
+		return ^value, nil
+	case "+":
+		return value, nil
+	case "-":
+		return -value, nil
+	}
+	return 0, fmt.Errorf("Unhandled unary operator %q", n.Operator)
+}
+
+func (e *evaluator) evalBinary(n *BinaryOp) (int64, error) {
+	left, err := e.eval(n.Left)
+	if err != nil {
+		return 0, err
+	}
+
+	// short circuit
+	switch n.Operator {
+	case "&&":
+		if left == 0 {
+			return 0, nil
+		}
+	case "||":
+		if left != 0 {
+			return 1, nil
+		}
+	}
+
+	right, err := e.eval(n.Right)
+	if err != nil {
+		return 0, err
+	}
+	return applyOperator(n.Operator, left, right)
+}
+
+func (e *evaluator) evalAssignment(n *Assignment) (int64, error) {
+	value, err := e.eval(n.Value)
+	if err != nil {
+		return 0, err
+	}
+
+	if n.Operator != "=" {
+		current, err := e.lookup(n.Name)
+		if err != nil {
+			return 0, err
+		}
+		// "+=" applies "+", and so on
+		op := strings.TrimSuffix(n.Operator, "=")
+		if value, err = applyOperator(op, current, value); err != nil {
+			return 0, err
+		}
+	}
+	return value, e.assign(n.Name, value)
+}
+
+func (e *evaluator) evalIncDec(n *IncDec) (int64, error) {
+	current, err := e.lookup(n.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	value := current + 1
+	if n.Operator == "--" {
+		value = current - 1
+	}
+	if err := e.assign(n.Name, value); err != nil {
+		return 0, err
+	}
+
+	if n.Prefix {
+		return value, nil
+	}
+	return current, nil
+}
+
+func applyOperator(op string, left, right int64) (int64, error) {
+	switch op {
+	case ",":
+		return right, nil
+	case "||":
+		return boolToInt(left != 0 || right != 0), nil
+	case "&&":
+		return boolToInt(left != 0 && right != 0), nil
+	case "|":
+		return left | right, nil
+	case "^":
+		return left ^ right, nil
+	case "&":
+		return left & right, nil
+	case "==":
+		return boolToInt(left == right), nil
+	case "!=":
+		return boolToInt(left != right), nil
+	case "<":
+		return boolToInt(left < right), nil
+	case "<=":
+		return boolToInt(left <= right), nil
+	case ">":
+		return boolToInt(left > right), nil
+	case ">=":
+		return boolToInt(left >= right), nil
+	case "<<":
+		return left << uint64(right&63), nil
+	case ">>":
+		return left >> uint64(right&63), nil
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "/", "%":
+		if right == 0 {
+			return 0, fmt.Errorf("division by 0")
+		}
+		if op == "/" {
+			return left / right, nil
+		}
+		return left % right, nil
+	}
+	return 0, fmt.Errorf("Unhandled binary operator %q", op)
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package arith
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type TokenType int
+
+const (
+	EOF TokenType = iota
+	Number
+	Name
+	Operator
+	LeftParen
+	RightParen
+)
+
+type Token struct {
+	Type TokenType
+	Text string
+	Pos  int
+}
+
+func (t Token) Format(f fmt.State, c rune) {
+	if t.Type == EOF {
+		fmt.Fprintf(f, "end of expression")
+	} else {
+		fmt.Fprintf(f, "%q", t.Text)
+	}
+}
+
+// sorted so that longer operators are matched first
+var OPERATORS = []string{
+	"<<=", ">>=",
+	"++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"+=", "-=", "*=", "/=", "%=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "&", "|", "^", "!", "~", "?", ":", "=", ",",
+}
+
+/* Split an arithmetic expression into tokens. The expression has already
+ * been expanded by the shell, so it contains only numbers, variable names,
+ * operators and parentheses. */
+func Tokenize(input string) ([]Token, error) {
+	tokens := []Token{}
+	pos := 0
+	for pos < len(input) {
+		c := rune(input[pos])
+		start := pos
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+			continue
+		case isDigit(c):
+			for pos < len(input) && isNameChar(rune(input[pos])) {
+				pos++
+			}
+			tokens = append(tokens, Token{Number, input[start:pos], start})
+		case isNameStart(c):
+			for pos < len(input) && isNameChar(rune(input[pos])) {
+				pos++
+			}
+			tokens = append(tokens, Token{Name, input[start:pos], start})
+		case c == '(':
+			pos++
+			tokens = append(tokens, Token{LeftParen, "(", start})
+		case c == ')':
+			pos++
+			tokens = append(tokens, Token{RightParen, ")", start})
+		default:
+			op := ""
+			for _, candidate := range OPERATORS {
+				if strings.HasPrefix(input[pos:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("syntax error: invalid arithmetic operator (error token is %q)", input[pos:])
+			}
+			pos += len(op)
+			tokens = append(tokens, Token{Operator, op, start})
+		}
+	}
+	tokens = append(tokens, Token{EOF, "", pos})
+	return tokens, nil
+}
+
+func isDigit(c rune) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isNameStart(c rune) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isNameChar(c rune) bool {
+	return isNameStart(c) || isDigit(c)
+}
@@ -0,0 +1,82 @@
+package arith
+
+import (
+	"fmt"
+)
+
+type Node interface {
+	fmt.Formatter
+}
+
+type NumberLiteral struct {
+	Value int64
+}
+
+type Variable struct {
+	Name string
+}
+
+/* A prefix operator, like "-x", "!x", or "~x" */
+type UnaryOp struct {
+	Operator string
+	Operand  Node
+}
+
+type BinaryOp struct {
+	Operator string
+	Left     Node
+	Right    Node
+}
+
+/* An assignment, like "x = 1" or "x += 2" */
+type Assignment struct {
+	Operator string
+	Name     string
+	Value    Node
+}
+
+/* An increment or decrement, like "++x" or "x--" */
+type IncDec struct {
+	Operator string
+	Name     string
+	Prefix   bool
+}
+
+/* The ternary operator, "cond ? then : else" */
+type Conditional struct {
+	Cond Node
+	Then Node
+	Else Node
+}
+
+func (n *NumberLiteral) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "%d", n.Value)
+}
+
+func (v *Variable) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "%s", v.Name)
+}
+
+func (u *UnaryOp) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "(%s%v)", u.Operator, u.Operand)
+}
+
+func (b *BinaryOp) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "(%v %s %v)", b.Left, b.Operator, b.Right)
+}
+
+func (a *Assignment) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "(%s %s %v)", a.Name, a.Operator, a.Value)
+}
+
+func (i *IncDec) Format(f fmt.State, c rune) {
+	if i.Prefix {
+		fmt.Fprintf(f, "(%s%s)", i.Operator, i.Name)
+	} else {
+		fmt.Fprintf(f, "(%s%s)", i.Name, i.Operator)
+	}
+}
+
+func (t *Conditional) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "(%v ? %v : %v)", t.Cond, t.Then, t.Else)
+}
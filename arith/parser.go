@@ -0,0 +1,246 @@
+package arith
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// binary operators grouped by precedence, from lowest to highest. all of
+// these are left-associative.
+var BINARY_PRECEDENCE = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+var ASSIGNMENT_OPERATORS = []string{
+	"=", "+=", "-=", "*=", "/=", "%=", "<<=", ">>=", "&=", "^=", "|=",
+}
+
+type Parser struct {
+	tokens []Token
+	pos    int
+}
+
+/* Parse an arithmetic expression, with C-style operators and precedence:
+ *
+ *	expr        := assignment ( "," assignment )*
+ *	assignment  := NAME assign-op assignment | conditional
+ *	conditional := binary [ "?" expr ":" conditional ]
+ *	binary      := unary ( binary-op unary )*
+ *	unary       := ( "!" | "~" | "+" | "-" ) unary | ( "++" | "--" ) NAME | postfix
+ *	postfix     := NAME ( "++" | "--" ) | primary
+ *	primary     := NUMBER | NAME | "(" expr ")"
+ */
+func Parse(input string) (Node, error) {
+	tokens, err := Tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Parser{tokens: tokens}
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.Type != EOF {
+		return nil, p.syntaxError(tok)
+	}
+	return node, nil
+}
+
+func (p *Parser) peek() Token {
+	return p.tokens[p.pos]
+}
+
+func (p *Parser) next() Token {
+	tok := p.tokens[p.pos]
+	if tok.Type != EOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *Parser) hasOperator(ops ...string) bool {
+	tok := p.peek()
+	if tok.Type != Operator {
+		return false
+	}
+	for _, op := range ops {
+		if tok.Text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) syntaxError(tok Token) error {
+	if tok.Type == EOF {
+		return fmt.Errorf("syntax error: operand expected")
+	}
+	return fmt.Errorf("syntax error in expression (error token is %v)", tok)
+}
+
+func (p *Parser) parseExpr() (Node, error) {
+	left, err := p.parseAssignment()
+	if err != nil {
+		return nil, err
+	}
+	for p.hasOperator(",") {
+		p.next()
+		right, err := p.parseAssignment()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryOp{Operator: ",", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *Parser) parseAssignment() (Node, error) {
+	// look ahead for NAME assign-op
+	if p.peek().Type == Name && p.pos+1 < len(p.tokens) {
+		op := p.tokens[p.pos+1]
+		for _, assign_op := range ASSIGNMENT_OPERATORS {
+			if op.Type == Operator && op.Text == assign_op {
+				name := p.next()
+				p.next()
+
+				// assignment is right-associative
+				value, err := p.parseAssignment()
+				if err != nil {
+					return nil, err
+				}
+				return &Assignment{Operator: op.Text, Name: name.Text, Value: value}, nil
+			}
+		}
+	}
+	return p.parseConditional()
+}
+
+func (p *Parser) parseConditional() (Node, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil || !p.hasOperator("?") {
+		return cond, err
+	}
+	p.next()
+
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.hasOperator(":") {
+		return nil, fmt.Errorf("syntax error: expected ':' for conditional expression (error token is %v)", p.peek())
+	}
+	p.next()
+
+	otherwise, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	return &Conditional{Cond: cond, Then: then, Else: otherwise}, nil
+}
+
+func (p *Parser) parseBinary(level int) (Node, error) {
+	if level >= len(BINARY_PRECEDENCE) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.hasOperator(BINARY_PRECEDENCE[level]...) {
+		op := p.next()
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryOp{Operator: op.Text, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *Parser) parseUnary() (Node, error) {
+	if p.hasOperator("!", "~", "+", "-") {
+		op := p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryOp{Operator: op.Text, Operand: operand}, nil
+	} else if p.hasOperator("++", "--") {
+		op := p.next()
+		name := p.next()
+		if name.Type != Name {
+			return nil, fmt.Errorf("syntax error: %v requires a variable name (error token is %v)", op, name)
+		}
+		return &IncDec{Operator: op.Text, Name: name.Text, Prefix: true}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *Parser) parsePostfix() (Node, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := node.(*Variable); ok && p.hasOperator("++", "--") {
+		op := p.next()
+		return &IncDec{Operator: op.Text, Name: v.Name, Prefix: false}, nil
+	}
+	return node, nil
+}
+
+func (p *Parser) parsePrimary() (Node, error) {
+	tok := p.next()
+	switch tok.Type {
+	case Number:
+		value, err := ParseNumber(tok.Text)
+		if err != nil {
+			return nil, err
+		}
+		return &NumberLiteral{Value: value}, nil
+	case Name:
+		return &Variable{Name: tok.Text}, nil
+	case LeftParen:
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.Type != RightParen {
+			return nil, fmt.Errorf("syntax error: missing ')' (error token is %v)", tok)
+		}
+		return node, nil
+	}
+	return nil, p.syntaxError(tok)
+}
+
+/* Parse an integer constant. A leading "0x" or "0X" means hexadecimal, and a
+ * leading "0" means octal. */
+func ParseNumber(text string) (int64, error) {
+	digits, base := text, 10
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+		digits, base = text[2:], 16
+	} else if len(text) > 1 && text[0] == '0' {
+		digits, base = text[1:], 8
+	}
+
+	value, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+			return 0, fmt.Errorf("value too large (error token is %q)", text)
+		}
+		return 0, fmt.Errorf("value too great for base (error token is %q)", text)
+	}
+	return value, nil
+}
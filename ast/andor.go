@@ -6,13 +6,20 @@ import (
 	"github.com/pglass/pshhh/lex"
 )
 
+/* A list of pipelines joined by "&&" or "||", like
+ *
+ *	a && b || c
+ *
+ * The operators have equal precedence and are evaluated from left to right.
+ * The list is stored as a chain: Left is the first pipeline, and the rest of
+ * the list follows the Operator in AndOrClause. */
 type AndOrClause struct {
 	Left        Node
 	Operator    *lex.Token
 	AndOrClause *AndOrClause
 }
 
-func (a *AndOrClause) IsExpr() {}
+func (a *AndOrClause) IsCommand() {}
 
 func NewAndOrClause(left Node) *AndOrClause {
 	return &AndOrClause{
@@ -36,6 +43,8 @@ func (a *AndOrClause) Format(f fmt.State, c rune) {
 	fmt.Fprintf(f, "]")
 }
 
+/* Parse the remainder of the list. This expects the first pipeline to have
+ * already been parsed. */
 func (a *AndOrClause) Parse(parser *Parser) error {
 	if !parser.Lexer.HasAnyToken(lex.AndIf, lex.OrIf) {
 		return nil
@@ -44,17 +53,17 @@ func (a *AndOrClause) Parse(parser *Parser) error {
 	tok := parser.Lexer.Next()
 	a.Operator = &tok
 
-	parser.ConsumeWhile(lex.Space)
+	// a newline is allowed after the operator
+	parser.ConsumeWhile(lex.Space, lex.Newline)
 
-	if node, err := parser.ParseNext(); err != nil {
+	if command, err := parsePipeline(parser); err != nil {
 		return err
-	} else if node != nil {
-		// todo: maybe only certain kinds of nodes are valid here
-		a.AndOrClause = NewAndOrClause(node)
-		if err := a.AndOrClause.Parse(parser); err != nil {
-			return err
-		}
+	} else if command == nil {
+		return fmt.Errorf("Syntax error near %q (expected command)", tok.Text)
+	} else {
+		a.AndOrClause = NewAndOrClause(command)
 	}
 
-	return nil
+	parser.ConsumeWhile(lex.Space)
+	return a.AndOrClause.Parse(parser)
 }
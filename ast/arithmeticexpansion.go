@@ -0,0 +1,37 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* An arithmetic expansion, like $((x + 1)). The expression may contain
+ * parameter expansions and command substitutions, so it is stored as a Str
+ * and parsed as an arithmetic expression after it is expanded. */
+type ArithmeticExpansion struct {
+	Expr *Str
+
+	// true if the expansion is within double quotes
+	Quoted bool
+}
+
+func NewArithmeticExpansion() *ArithmeticExpansion {
+	return &ArithmeticExpansion{Expr: NewStr()}
+}
+
+func (a *ArithmeticExpansion) IsStrPiece() {}
+
+func (a *ArithmeticExpansion) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "ArithmeticExpansion[%v]", a.Expr)
+}
+
+func (a *ArithmeticExpansion) Parse(parser *Parser) error {
+	if _, err := parser.ConsumeToken(lex.Dollar, nil); err != nil {
+		return err
+	}
+	if _, err := parser.ConsumeToken(lex.DoubleLeftParen, nil); err != nil {
+		return err
+	}
+	return a.Expr.parseStringContents(parser, lex.DoubleRightParen)
+}
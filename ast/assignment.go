@@ -0,0 +1,49 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* NAME=value, either on its own or before the words of a simple command:
+ *
+ *	FOO=bar
+ *	FOO=bar BAR="$FOO" cmd
+ *
+ * The value is expanded like a word, but is never split. */
+type Assignment struct {
+	Name  string
+	Value *Str
+}
+
+func NewAssignment() *Assignment {
+	return &Assignment{Value: NewStr()}
+}
+
+func (a *Assignment) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "Assign[%v=%v]", a.Name, a.Value)
+}
+
+func (a *Assignment) Parse(parser *Parser) error {
+	tok := parser.Lexer.Peek()
+	if tok.Type != lex.AssignmentWord {
+		return fmt.Errorf("Syntax error near %v (expected an assignment)", tok)
+	}
+
+	word := NewStr()
+	if err := word.Parse(parser); err != nil {
+		return err
+	}
+
+	// the name is everything before the first '=' in the first piece
+	first := string(word.Pieces[0].(RawStr))
+	index := strings.IndexRune(first, '=')
+	a.Name = first[:index]
+	if value := first[index+1:]; value != "" {
+		a.Value.Pieces = append(a.Value.Pieces, RawStr(value))
+	}
+	a.Value.Pieces = append(a.Value.Pieces, word.Pieces[1:]...)
+	return nil
+}
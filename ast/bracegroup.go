@@ -0,0 +1,55 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* { <body> }
+ *
+ * A brace group runs its body in the current shell. This is most often the
+ * body of a function. */
+type BraceGroup struct {
+	Body      []Node
+	Redirects []*IoRedirect
+}
+
+func NewBraceGroup() *BraceGroup {
+	return &BraceGroup{
+		Body:      []Node{},
+		Redirects: []*IoRedirect{},
+	}
+}
+
+func (b *BraceGroup) IsCommand() {}
+
+func (b *BraceGroup) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "BraceGroup%v", b.Body)
+	for _, redirect := range b.Redirects {
+		fmt.Fprintf(f, " %v", redirect)
+	}
+}
+
+func (b *BraceGroup) Parse(parser *Parser) error {
+	// "{"
+	if _, err := parser.ConsumeToken(lex.LeftBrace, nil); err != nil {
+		return err
+	}
+
+	if nodes, err := parser.ParseUntil(lex.RightBrace); err != nil {
+		return err
+	} else if len(nodes) == 0 {
+		return fmt.Errorf("Empty {...} block")
+	} else {
+		b.Body = nodes
+	}
+	parser.Lexer.Next()
+
+	if redirects, err := parseRedirects(parser); err != nil {
+		return err
+	} else {
+		b.Redirects = redirects
+	}
+	return nil
+}
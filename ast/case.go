@@ -0,0 +1,156 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* case <word> in [(]<pattern> [| <pattern>]...) <body> ;; ... esac
+ *
+ * The body of the first item with a pattern matching the word is run. */
+type CaseClause struct {
+	Word      *Str
+	Items     []*CaseItem
+	Redirects []*IoRedirect
+}
+
+/* A single "pattern) body ;;" in a case clause. The terminator is one of
+ *
+ *	;;	stop after running the body
+ *	;&	also run the body of the next item
+ *	;;&	continue matching against the next items
+ *
+ * The terminator of the last item may be omitted, in which case the
+ * terminator is DoubleSemi. */
+type CaseItem struct {
+	Patterns   []*Str
+	Body       []Node
+	Terminator lex.TokenType
+}
+
+func NewCaseClause() *CaseClause {
+	return &CaseClause{
+		Items:     []*CaseItem{},
+		Redirects: []*IoRedirect{},
+	}
+}
+
+func NewCaseItem() *CaseItem {
+	return &CaseItem{
+		Patterns:   []*Str{},
+		Body:       []Node{},
+		Terminator: lex.DoubleSemi,
+	}
+}
+
+func (c *CaseClause) IsCommand() {}
+
+func (c *CaseClause) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "Case[%v in", c.Word)
+	for _, item := range c.Items {
+		fmt.Fprintf(f, " %v", item)
+	}
+	fmt.Fprintf(f, "]")
+	for _, redirect := range c.Redirects {
+		fmt.Fprintf(f, " %v", redirect)
+	}
+}
+
+func (c *CaseItem) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "%v) %v %v", c.Patterns, c.Body, c.Terminator)
+}
+
+func (c *CaseClause) Parse(parser *Parser) error {
+	// "case"
+	if _, err := parser.ConsumeToken(lex.Case, nil); err != nil {
+		return err
+	}
+
+	parser.ConsumeWhile(lex.Space)
+
+	// "<word>"
+	if !parser.HasWord() {
+		return fmt.Errorf("Syntax error near %v (expected a word after \"case\")", parser.Lexer.Peek())
+	}
+	c.Word = NewStr()
+	if err := c.Word.Parse(parser); err != nil {
+		return err
+	}
+
+	parser.ConsumeWhile(lex.Space, lex.Newline)
+
+	// "in"
+	if tok := parser.Lexer.PeekReserved(lex.In); tok.Type == lex.EOF {
+		return fmt.Errorf("Unexpected end of input (expected In)")
+	} else if _, err := parser.ConsumeToken(lex.In, nil); err != nil {
+		return err
+	}
+
+	for {
+		parser.ConsumeWhile(lex.Space, lex.Newline)
+
+		tok := parser.Lexer.PeekReserved(lex.Esac)
+		if tok.Type == lex.Esac {
+			parser.Lexer.Next()
+			break
+		} else if tok.Type == lex.EOF {
+			return fmt.Errorf("Unexpected end of input (expected Esac)")
+		}
+
+		item := NewCaseItem()
+		if err := item.Parse(parser); err != nil {
+			return err
+		}
+		c.Items = append(c.Items, item)
+	}
+
+	if redirects, err := parseRedirects(parser); err != nil {
+		return err
+	} else {
+		c.Redirects = redirects
+	}
+	return nil
+}
+
+/* Parse one item, up to and including the terminator. The "esac" after the
+ * last item is not consumed. */
+func (c *CaseItem) Parse(parser *Parser) error {
+	// the optional "(" before the patterns
+	parser.ConsumeWhile(lex.LeftParen)
+
+	for {
+		parser.ConsumeWhile(lex.Space)
+
+		if !parser.HasWord() {
+			return fmt.Errorf("Syntax error near %v (expected a pattern)", parser.Lexer.Peek())
+		}
+		pattern := NewStr()
+		if err := pattern.Parse(parser); err != nil {
+			return err
+		}
+		c.Patterns = append(c.Patterns, pattern)
+
+		parser.ConsumeWhile(lex.Space)
+
+		// "|" separates the patterns, and ")" ends them
+		if tok, err := parser.ConsumeAny(lex.Pipe, lex.RightParen); err != nil {
+			return err
+		} else if tok.Type == lex.RightParen {
+			break
+		}
+	}
+
+	// the body may be empty
+	if nodes, err := parser.ParseUntil(lex.DoubleSemi, lex.SemiAnd, lex.DoubleSemiAnd, lex.Esac); err != nil {
+		return err
+	} else {
+		c.Body = nodes
+	}
+
+	if tok := parser.Lexer.Peek(); tok.Type != lex.Esac {
+		parser.Lexer.Next()
+		c.Terminator = tok.Type
+	}
+	return nil
+}
@@ -6,7 +6,8 @@ import (
 	"github.com/pglass/pshhh/lex"
 )
 
-/* A list of commands joined by either ';' or '&' */
+/* A list of commands joined by either ';' or '&'. Each command may be a
+ * Pipeline, or an AndOrClause of pipelines. */
 type CommandList struct {
 	Commands   []Command
 	Separators []lex.Token
@@ -40,7 +41,7 @@ func (c *CommandList) Parse(parser *Parser) error {
 		parser.ConsumeWhile(lex.Space)
 
 		// parse the command
-		if command, err := c.parseCommand(parser); err != nil {
+		if command, err := parseAndOr(parser); err != nil {
 			return err
 		} else if command == nil && len(c.Commands) != 0 {
 			break
@@ -61,25 +62,60 @@ func (c *CommandList) Parse(parser *Parser) error {
 	return nil
 }
 
-func (c *CommandList) parseCommand(parser *Parser) (Command, error) {
-	tok := parser.Lexer.Peek()
+/* Parse a pipeline. If the pipeline is followed by "&&" or "||", this parses
+ * and returns the entire AndOrClause. */
+func parseAndOr(parser *Parser) (Command, error) {
+	command, err := parsePipeline(parser)
+	if err != nil || command == nil {
+		return command, err
+	}
 
-	var command Command = nil
-	switch tok.Type {
-	case lex.EOF:
-		return nil, nil
-	case lex.ERROR:
-		return nil, fmt.Errorf("%v", tok.Text)
-	case lex.For:
-		command = NewForClause()
-	case lex.Word, lex.Name, lex.Number:
-		command = NewSimpleCommand()
-	default:
+	parser.ConsumeWhile(lex.Space)
+
+	if !parser.Lexer.HasAnyToken(lex.AndIf, lex.OrIf) {
+		return command, nil
+	}
+
+	clause := NewAndOrClause(command)
+	if err := clause.Parse(parser); err != nil {
+		return nil, err
+	}
+	return clause, nil
+}
+
+/* Parse a single command. If the command is followed by '|', or preceded by
+ * '!', this parses and returns the entire Pipeline. */
+func parsePipeline(parser *Parser) (Command, error) {
+	negated := false
+	if parser.Lexer.PeekReserved().Type == lex.Bang {
+		parser.Lexer.Next()
+		parser.ConsumeWhile(lex.Space)
+		negated = true
+	}
+
+	command, err := parser.ParseCommand()
+	if err != nil {
+		return nil, err
+	} else if command == nil {
+		if negated {
+			return nil, fmt.Errorf("Syntax error near \"!\" (expected command)")
+		}
 		return nil, nil
 	}
 
-	if err := command.Parse(parser); err != nil {
+	parser.ConsumeWhile(lex.Space)
+
+	if !parser.Lexer.HasAnyToken(lex.Pipe) {
+		if negated {
+			return &Pipeline{Commands: []Command{command}, Negated: true}, nil
+		}
+		return command, nil
+	}
+
+	pipeline := NewPipeline(command)
+	pipeline.Negated = negated
+	if err := pipeline.Parse(parser); err != nil {
 		return nil, err
 	}
-	return command, nil
+	return pipeline, nil
 }
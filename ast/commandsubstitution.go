@@ -0,0 +1,61 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* A command substitution is replaced by the output of a command, like
+ *
+ *	$(cmd)
+ *	`cmd`
+ */
+type CommandSubstitution struct {
+	Program *GenericNode
+
+	// true if the substitution is within double quotes
+	Quoted bool
+}
+
+func NewCommandSubstitution() *CommandSubstitution {
+	return &CommandSubstitution{Program: NewGenericNode()}
+}
+
+func (c *CommandSubstitution) IsStrPiece() {}
+
+func (c *CommandSubstitution) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "CommandSubstitution[%v]", c.Program)
+}
+
+func (c *CommandSubstitution) Parse(parser *Parser) error {
+	if parser.Lexer.HasAnyToken(lex.BackquotedCommand) {
+		// `cmd` -- the lexer gives us the command as a single token
+		tok := parser.Lexer.Next()
+		if node, err := NewParser(lex.NewLexerAt(tok.Text, tok.Line)).Parse(); err != nil {
+			return err
+		} else {
+			c.Program = node.(*GenericNode)
+		}
+		return nil
+	}
+
+	// $ ( cmd )
+	if _, err := parser.ConsumeToken(lex.Dollar, nil); err != nil {
+		return err
+	}
+	if _, err := parser.ConsumeToken(lex.LeftParen, nil); err != nil {
+		return err
+	}
+
+	if nodes, err := parser.ParseUntil(lex.RightParen); err != nil {
+		return err
+	} else {
+		c.Program.Children = nodes
+	}
+
+	if _, err := parser.ConsumeToken(lex.RightParen, nil); err != nil {
+		return err
+	}
+	return nil
+}
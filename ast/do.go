@@ -20,28 +20,19 @@ func (d *DoClause) Format(f fmt.State, c rune) {
 
 func (d *DoClause) Parse(parser *Parser) error {
 	// "do"
+	parser.Lexer.PeekReserved(lex.Do)
 	if _, err := parser.ConsumeToken(lex.Do, nil); err != nil {
 		return err
 	}
 
-	for {
-		parser.ConsumeWhile(lex.Space, lex.Newline)
-
-		// stop when we see "done"
-		if parser.Lexer.HasAnyToken(lex.Done) {
-			parser.Lexer.Next()
-			break
-		}
-
-		parser.ConsumeWhile(lex.Space, lex.Newline)
-
-		if node, err := parser.ParseNext(); node != nil {
-			// todo: only certain node types are valid here?
-			d.Children = append(d.Children, node)
-		} else if err != nil {
-			return err
-		}
+	// stop when we see "done"
+	// todo: only certain node types are valid here?
+	if nodes, err := parser.ParseUntil(lex.Done); err != nil {
+		return err
+	} else {
+		d.Children = nodes
 	}
+	parser.Lexer.Next()
 
 	if len(d.Children) == 0 {
 		return fmt.Errorf("Empty do...done block")
@@ -10,25 +10,24 @@ type ForClause struct {
 	LoopVar *lex.Token
 	In      *lex.Token
 
-	// an OPTIONAL list of Words and Names
-	// note: expansions must occur before parsing. If you have
+	// an OPTIONAL list of words. The words are expanded when the loop runs,
+	// so in
 	//
-	//		for x in `seq 1 3`
+	//		for x in `seq 1 3` $Y
 	//
-	// The expression `seq 1 3` is command substitution (in bash). In order
-	// to execute the for loop, we would first tokenize. We would go through
-	// the tokens and run the command `seq 1 3` to get "1\n2\n3\n", which we
-	// would tokenize to tokens "1", "\n", "2", "\n", "3", "\n". These tokens
-	// would then replace `seq 1 3` in our token stream.
-	Wordlist []lex.Token
-
-	DoClause *DoClause
+	// the command substitution and the parameter expansion each produce the
+	// items the loop iterates over.
+	Wordlist []*Str
+
+	DoClause  *DoClause
+	Redirects []*IoRedirect
 }
 
 func NewForClause() *ForClause {
 	return &ForClause{
-		Wordlist: []lex.Token{},
-		DoClause: NewDoClause(),
+		Wordlist:  []*Str{},
+		DoClause:  NewDoClause(),
+		Redirects: []*IoRedirect{},
 	}
 }
 
@@ -39,6 +38,10 @@ func (f *ForClause) Format(fs fmt.State, c rune) {
 	if f.In != nil {
 		fmt.Fprintf(fs, " in %v", f.Wordlist)
 	}
+	fmt.Fprintf(fs, " %v", f.DoClause)
+	for _, redirect := range f.Redirects {
+		fmt.Fprintf(fs, " %v", redirect)
+	}
 }
 
 func (f *ForClause) Parse(parser *Parser) error {
@@ -66,34 +69,36 @@ func (f *ForClause) Parse(parser *Parser) error {
 		return err
 	}
 
+	if redirects, err := parseRedirects(parser); err != nil {
+		return err
+	} else {
+		f.Redirects = redirects
+	}
+
 	return nil
 }
 
 func (f *ForClause) parseOptionalInClause(parser *Parser) error {
-	if !parser.Lexer.HasAnyToken(lex.In) {
-		return nil
+	if parser.Lexer.PeekReserved(lex.In).Type == lex.In {
+		// consume "in"
+		if _, err := parser.ConsumeToken(lex.In, &f.In); err != nil {
+			return err
+		}
+
+		// " "
+		parser.ConsumeWhile(lex.Space)
+
+		// consume and store the word list
+		if words, err := parser.ParseWordlist(); err != nil {
+			return err
+		} else {
+			f.Wordlist = words
+		}
+
+		// " "
+		parser.ConsumeWhile(lex.Space)
 	}
 
-	// consume "in"
-	if _, err := parser.ConsumeToken(lex.In, &f.In); err != nil {
-		return err
-	}
-
-	// " "
-	parser.ConsumeWhile(lex.Space)
-
-	// consume and store the word list
-	f.Wordlist = append(f.Wordlist, parser.ConsumeWordlist()...)
-	// for _, tok := range parser.ConsumeWhile(lex.Word, lex.Name, lex.Space) {
-	// 	switch tok.Type {
-	// 	case lex.Word, lex.Name:
-	// 		f.Wordlist = append(f.Wordlist, tok)
-	// 	}
-	// }
-
-	// " "
-	parser.ConsumeWhile(lex.Space)
-
 	// the separator is either ';' or '\n'
 	// if there is no in clause, we don't *need* a separator
 	//
@@ -0,0 +1,86 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* A function definition, in either of the forms
+ *
+ *	name() <compound command>
+ *	function name [()] <compound command>
+ *
+ * The body is usually a brace group, but may be any compound command. Any
+ * redirects after the body apply each time the function runs. */
+type FunctionDefinition struct {
+	Name *lex.Token
+	Body Command
+}
+
+func NewFunctionDefinition() *FunctionDefinition {
+	return &FunctionDefinition{}
+}
+
+func (d *FunctionDefinition) IsCommand() {}
+
+func (d *FunctionDefinition) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "Function %q %v", d.Name.Text, d.Body)
+}
+
+func (d *FunctionDefinition) Parse(parser *Parser) error {
+	// the optional "function"
+	if parser.Lexer.HasAnyToken(lex.Function) {
+		parser.Lexer.Next()
+		parser.ConsumeWhile(lex.Space)
+	}
+
+	// "<name>"
+	if tok := parser.Lexer.Peek(); tok.Type == lex.EOF {
+		return fmt.Errorf("Unexpected end of input (expected a function name)")
+	} else if _, err := parser.ConsumeToken(lex.Name, &d.Name); err != nil {
+		return err
+	}
+
+	parser.ConsumeWhile(lex.Space)
+
+	// "()", which is optional after "function"
+	if parser.Lexer.HasAnyToken(lex.LeftParen) {
+		parser.Lexer.Next()
+		parser.ConsumeWhile(lex.Space)
+		if _, err := parser.ConsumeToken(lex.RightParen, nil); err != nil {
+			return err
+		}
+	}
+
+	parser.ConsumeWhile(lex.Space, lex.Newline)
+
+	tok := parser.Lexer.Peek()
+	if body, err := parser.ParseCommand(); err != nil {
+		return err
+	} else if body == nil {
+		return fmt.Errorf("Syntax error near %v (expected a function body)", tok)
+	} else if _, ok := body.(*SimpleCommand); ok {
+		return fmt.Errorf("Syntax error near %v (expected a compound command)", tok)
+	} else {
+		d.Body = body
+	}
+	return nil
+}
+
+/* Returns true if the next tokens are "name ()", the start of a function
+ * definition. No tokens are consumed. */
+func (p *Parser) hasFunctionDefinition() bool {
+	if !p.Lexer.HasAnyToken(lex.Name) {
+		return false
+	}
+
+	seen := []lex.Token{p.Lexer.Next()}
+	seen = append(seen, p.ConsumeWhile(lex.Space)...)
+	result := p.Lexer.HasAnyToken(lex.LeftParen)
+
+	for j := len(seen) - 1; j >= 0; j-- {
+		p.Lexer.Unread(seen[j])
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* if <condition> then <body> [elif <condition> then <body>]... [else <body>] fi
+ *
+ * An elif is stored as a nested IfClause, which is the only node in Else. */
+type IfClause struct {
+	Condition []Node
+	Body      []Node
+	Else      []Node
+	Redirects []*IoRedirect
+}
+
+func NewIfClause() *IfClause {
+	return &IfClause{
+		Condition: []Node{},
+		Body:      []Node{},
+		Else:      nil,
+		Redirects: []*IoRedirect{},
+	}
+}
+
+func (c *IfClause) IsCommand() {}
+
+func (c *IfClause) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "If[%v Then %v", c.Condition, c.Body)
+	if c.Else != nil {
+		fmt.Fprintf(f, " Else %v", c.Else)
+	}
+	fmt.Fprintf(f, "]")
+	for _, redirect := range c.Redirects {
+		fmt.Fprintf(f, " %v", redirect)
+	}
+}
+
+func (c *IfClause) Parse(parser *Parser) error {
+	// "if"
+	if _, err := parser.ConsumeToken(lex.If, nil); err != nil {
+		return err
+	}
+
+	if err := c.parseClause(parser); err != nil {
+		return err
+	}
+
+	if redirects, err := parseRedirects(parser); err != nil {
+		return err
+	} else {
+		c.Redirects = redirects
+	}
+	return nil
+}
+
+/* Parse everything after the "if" (or "elif"), up to and including the "fi" */
+func (c *IfClause) parseClause(parser *Parser) error {
+	if nodes, err := parser.ParseUntil(lex.Then); err != nil {
+		return err
+	} else if len(nodes) == 0 {
+		return fmt.Errorf("Syntax error near \"then\" (expected a condition)")
+	} else {
+		c.Condition = nodes
+	}
+	parser.Lexer.Next()
+
+	if nodes, err := parser.ParseUntil(lex.Elif, lex.Else, lex.Fi); err != nil {
+		return err
+	} else if len(nodes) == 0 {
+		return fmt.Errorf("Empty then...fi block")
+	} else {
+		c.Body = nodes
+	}
+
+	switch tok := parser.Lexer.Next(); tok.Type {
+	case lex.Elif:
+		elif := NewIfClause()
+		if err := elif.parseClause(parser); err != nil {
+			return err
+		}
+		c.Else = []Node{elif}
+	case lex.Else:
+		if nodes, err := parser.ParseUntil(lex.Fi); err != nil {
+			return err
+		} else if len(nodes) == 0 {
+			return fmt.Errorf("Empty else...fi block")
+		} else {
+			c.Else = nodes
+		}
+		parser.Lexer.Next()
+	}
+	return nil
+}
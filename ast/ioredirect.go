@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pglass/pshhh/lex"
 )
@@ -10,7 +11,10 @@ type IoRedirect struct {
 	parser            *Parser
 	IoNumber          *lex.Token
 	IoOperator        *lex.Token
-	FilenameOrHereEnd *lex.Token
+	FilenameOrHereEnd *Str
+
+	// the body of a here-document ("<<" or "<<-")
+	HereDoc *Str
 }
 
 func NewIoRedirect(parser *Parser) *IoRedirect {
@@ -27,7 +31,26 @@ func (i *IoRedirect) Format(f fmt.State, c rune) {
 		fmt.Fprintf(f, "%v", i.IoNumber.Text)
 	}
 	fmt.Fprintf(f, "%v", i.IoOperator.Text)
-	fmt.Fprintf(f, "%v", i.FilenameOrHereEnd.Text)
+	fmt.Fprintf(f, "%v", i.FilenameOrHereEnd)
+}
+
+/* Parse the redirects following a compound command, like the "> out" in
+ *
+ *	if true; then echo hello; fi > out
+ */
+func parseRedirects(parser *Parser) ([]*IoRedirect, error) {
+	redirects := []*IoRedirect{}
+	for {
+		parser.ConsumeWhile(lex.Space)
+
+		redirect := NewIoRedirect(parser)
+		if err := redirect.Parse(); err != nil {
+			return nil, err
+		} else if redirect.IoOperator == nil {
+			return redirects, nil
+		}
+		redirects = append(redirects, redirect)
+	}
 }
 
 func (i *IoRedirect) Parse() error {
@@ -52,12 +75,46 @@ func (i *IoRedirect) Parse() error {
 
 	i.parser.ConsumeWhile(lex.Space)
 
+	if tok.Type == lex.DoubleLess || tok.Type == lex.DoubleLessDash {
+		return i.parseHereDoc()
+	}
+
 	// at this point, we've seen the operator, so there must be a word following
-	tok, err := i.parser.ConsumeAny(lex.Word, lex.Name, lex.Number)
-	if err != nil {
+	if !i.parser.HasWord() {
+		return fmt.Errorf("Syntax error near %q (expected a word after the redirect)", tok.Text)
+	}
+	word := NewStr()
+	if err := word.Parse(i.parser); err != nil {
 		return err
 	}
-	i.FilenameOrHereEnd = tok
+	i.FilenameOrHereEnd = word
 
 	return nil
 }
+
+/* The lexer emits the here-document delimiter as a Word, followed by the
+ * body of the here-document. If any part of the delimiter is quoted, the
+ * body is used as-is. Otherwise, the body may contain parameter expansions. */
+func (i *IoRedirect) parseHereDoc() error {
+	delim, err := i.parser.ConsumeAny(lex.Word)
+	if err != nil {
+		return fmt.Errorf("Syntax error near %q (expected here-document delimiter)",
+			i.IoOperator.Text)
+	}
+	i.FilenameOrHereEnd = NewStrFromTok(*delim)
+
+	body, err := i.parser.ConsumeAny(lex.HereDoc)
+	if err != nil {
+		return err
+	}
+
+	if strings.ContainsAny(delim.Text, `'"\`) {
+		i.HereDoc = NewStrFromTok(*body)
+		return nil
+	}
+
+	// parse any expansions in the body
+	parser := NewParser(lex.NewHereDocLexer(body.Text, body.Line))
+	i.HereDoc = NewStr()
+	return i.HereDoc.parseStringContents(parser, lex.EOF)
+}
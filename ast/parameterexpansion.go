@@ -10,6 +10,9 @@ type ParameterExpansion struct {
 	VarName  *lex.Token
 	Operator *lex.Token
 	Word     *Str
+
+	// true if the expansion is within double quotes, like "$@"
+	Quoted bool
 }
 
 func (p *ParameterExpansion) IsStrPiece() {}
@@ -37,7 +40,7 @@ func (p *ParameterExpansion) Parse(parser *Parser) error {
 	if parser.Lexer.HasAnyToken(lex.LeftBrace) {
 		// $ { NAME OP WORD }
 		parser.ConsumeToken(lex.LeftBrace, nil)
-		if _, err := parser.ConsumeToken(lex.Name, &p.VarName); err != nil {
+		if err := p.parseName(parser); err != nil {
 			return err
 		}
 
@@ -56,9 +59,27 @@ func (p *ParameterExpansion) Parse(parser *Parser) error {
 			return err
 		}
 		return nil
-	} else if _, err := parser.ConsumeToken(lex.Name, &p.VarName); err != nil {
+	} else if err := p.parseName(parser); err != nil {
 		// $ NAME
 		return err
 	}
 	return nil
 }
+
+/* Parse the name of the parameter, which is either a variable name, a
+ * special parameter, like the "?" in $?, or the number of a positional
+ * parameter */
+func (p *ParameterExpansion) parseName(parser *Parser) error {
+	if parser.Lexer.HasAnyToken(lex.SpecialParameter, lex.Number) {
+		tok := parser.Lexer.Next()
+		p.VarName = &tok
+		return nil
+	}
+	if tok, err := parser.ConsumeToken(lex.Name, &p.VarName); err != nil {
+		return err
+	} else if tok == nil {
+		// like "${" at the end of the input
+		return fmt.Errorf("Bad substitution (expected a parameter name)")
+	}
+	return nil
+}
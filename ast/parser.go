@@ -21,10 +21,10 @@ func NewParser(lexer *lex.Lexer) *Parser {
 }
 
 func (p *Parser) Parse() (Node, error) {
-	// ignore leading spaces
-	p.ConsumeWhile(lex.Space, lex.Newline)
-
 	for {
+		// ignore leading spaces and blank lines
+		p.ConsumeWhile(lex.Space, lex.Newline)
+
 		node, err := p.ParseNext()
 		if err != nil {
 			return nil, err
@@ -41,15 +41,17 @@ func (p *Parser) Parse() (Node, error) {
  * can be parsed (on EOF, for example). Returns an error if tokens remain in
  * the lexer but cannot be consumed */
 func (p *Parser) ParseNext() (Node, error) {
-	token := p.Lexer.Peek()
+	token := p.Lexer.PeekReserved()
 	switch token.Type {
 	case lex.EOF:
 		return nil, nil
 	case lex.ERROR:
 		return nil, fmt.Errorf(token.Text)
-	case lex.AndIf, lex.OrIf, lex.DoubleQuote, lex.StringSegment, lex.Dollar:
-		return p.ParseExpr(token)
-	case lex.For, lex.If, lex.Case, lex.While, lex.Until, lex.Word, lex.Name, lex.Number:
+	case lex.For, lex.If, lex.Case, lex.While, lex.Until, lex.Function, lex.LeftBrace,
+		lex.LeftParen, lex.Bang, lex.Word, lex.Name, lex.Number, lex.AssignmentWord,
+		lex.DoubleQuote, lex.SingleQuote, lex.Dollar, lex.BackquotedCommand, lex.EscapedChar,
+		lex.Less, lex.LessAnd, lex.Great, lex.GreatAnd, lex.DoubleGreat, lex.LessGreat,
+		lex.Clobber, lex.DoubleLess, lex.DoubleLessDash:
 		command_list := NewCommandList()
 		err := command_list.Parse(p)
 		node := command_list
@@ -59,26 +61,74 @@ func (p *Parser) ParseNext() (Node, error) {
 	}
 }
 
-func (p *Parser) ParseExpr(peekToken lex.Token) (Expr, error) {
-	var expr Expr = nil
-	switch peekToken.Type {
-	case lex.AndIf, lex.OrIf:
-		if len(p.Root.Children) == 0 {
-			return nil, fmt.Errorf(`Syntax error near %q`, peekToken.Text)
+/* Parse nodes until the next token is one of the given types. The final
+ * token is not consumed. Returns an error if no more nodes can be parsed
+ * before seeing one of the given token types. */
+func (p *Parser) ParseUntil(ttypes ...lex.TokenType) ([]Node, error) {
+	nodes := []Node{}
+	for {
+		p.ConsumeWhile(lex.Space, lex.Newline)
+		p.Lexer.PeekReserved()
+		if p.Lexer.HasAnyToken(ttypes...) {
+			return nodes, nil
+		}
+
+		if node, err := p.ParseNext(); err != nil {
+			return nil, err
+		} else if node != nil {
+			nodes = append(nodes, node)
+		} else if tok := p.Lexer.Peek(); tok.Type == lex.EOF {
+			return nil, fmt.Errorf("Unexpected end of input (expected any of %v)", ttypes)
+		} else {
+			return nil, fmt.Errorf("Syntax error near %v (expected any of %v)", tok, ttypes)
+		}
+	}
+}
+
+/* Parse a single command. Returns (nil, nil) if the next token cannot start a
+ * command. */
+func (p *Parser) ParseCommand() (Command, error) {
+	tok := p.Lexer.PeekReserved()
+
+	var command Command = nil
+	switch tok.Type {
+	case lex.EOF:
+		return nil, nil
+	case lex.ERROR:
+		return nil, fmt.Errorf("%v", tok.Text)
+	case lex.For:
+		command = NewForClause()
+	case lex.If:
+		command = NewIfClause()
+	case lex.Case:
+		command = NewCaseClause()
+	case lex.While:
+		command = NewWhileClause()
+	case lex.Until:
+		command = NewUntilClause()
+	case lex.LeftBrace:
+		command = NewBraceGroup()
+	case lex.LeftParen:
+		command = NewSubshell()
+	case lex.Function:
+		command = NewFunctionDefinition()
+	case lex.Word, lex.Name, lex.Number, lex.AssignmentWord, lex.DoubleQuote, lex.SingleQuote,
+		lex.Dollar, lex.BackquotedCommand, lex.EscapedChar, lex.Less, lex.LessAnd, lex.Great,
+		lex.GreatAnd, lex.DoubleGreat, lex.LessGreat, lex.Clobber, lex.DoubleLess, lex.DoubleLessDash:
+		if p.hasFunctionDefinition() {
+			command = NewFunctionDefinition()
+		} else {
+			// a simple command may be only redirects, like "> file"
+			command = NewSimpleCommand()
 		}
-		left := p.Root.Children[0]
-		p.Root.Children = p.Root.Children[1:]
-		expr = NewAndOrClause(left)
-	case lex.DoubleQuote, lex.StringSegment, lex.Dollar:
-		expr = NewStr()
 	default:
 		return nil, nil
 	}
 
-	if err := expr.Parse(p); err != nil {
+	if err := command.Parse(p); err != nil {
 		return nil, err
 	}
-	return expr, nil
+	return command, nil
 }
 
 func (p *Parser) ConsumeWhile(ttypes ...lex.TokenType) []lex.Token {
@@ -135,15 +185,29 @@ func (p *Parser) ConsumeAny(ttypes ...lex.TokenType) (*lex.Token, error) {
 	return nil, fmt.Errorf("Expected token of any type %v (got %v)", ttypes, tok.Type)
 }
 
-func (p *Parser) ConsumeWordlist() []lex.Token {
-	tokens := []lex.Token{}
-	consumed := p.ConsumeWhile(lex.Word, lex.Name, lex.Number, lex.Space)
-	for _, tok := range consumed {
-		if tok.Type == lex.EOF || tok.Type == lex.ERROR {
-			break
-		} else if tok.Type != lex.Space {
-			tokens = append(tokens, tok)
+/* Parse a list of words separated by spaces, like the "a $B c" in
+ *
+ *	for x in a $B c; do ...
+ */
+func (p *Parser) ParseWordlist() ([]*Str, error) {
+	words := []*Str{}
+	for {
+		p.ConsumeWhile(lex.Space)
+
+		if !p.HasWord() {
+			return words, nil
+		}
+
+		word := NewStr()
+		if err := word.Parse(p); err != nil {
+			return nil, err
 		}
+		words = append(words, word)
 	}
-	return tokens
+}
+
+/* Returns true if the next token starts a word */
+func (p *Parser) HasWord() bool {
+	return p.Lexer.HasAnyToken(lex.Word, lex.Name, lex.Number, lex.AssignmentWord,
+		lex.Dollar, lex.DoubleQuote, lex.SingleQuote, lex.BackquotedCommand, lex.EscapedChar)
 }
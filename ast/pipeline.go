@@ -0,0 +1,64 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* A sequence of commands joined by '|'. The stdout of each command is
+ * connected to the stdin of the next command.
+ *
+ * A pipeline preceded by '!' is Negated, and its exit status is logically
+ * inverted. A negated pipeline may have a single command. */
+type Pipeline struct {
+	Commands []Command
+	Negated  bool
+}
+
+func NewPipeline(commands ...Command) *Pipeline {
+	return &Pipeline{Commands: commands}
+}
+
+func (p *Pipeline) IsCommand() {}
+
+func (p *Pipeline) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "Pipeline[")
+	if p.Negated {
+		fmt.Fprintf(f, "! ")
+	}
+	if len(p.Commands) > 0 {
+		fmt.Fprintf(f, "%v", p.Commands[0])
+		for _, command := range p.Commands[1:] {
+			fmt.Fprintf(f, " | %v", command)
+		}
+	}
+	fmt.Fprintf(f, "]")
+}
+
+/* Parse the remainder of a pipeline. This expects the first command of the
+ * pipeline to have already been parsed, and it expects the next token to be
+ * a '|' */
+func (p *Pipeline) Parse(parser *Parser) error {
+	for parser.Lexer.HasAnyToken(lex.Pipe) {
+		tok := parser.Lexer.Next()
+
+		// a newline is allowed after the '|'
+		parser.ConsumeWhile(lex.Space, lex.Newline)
+
+		if command, err := parser.ParseCommand(); err != nil {
+			return err
+		} else if command == nil {
+			return fmt.Errorf("Syntax error near %q (expected command)", tok.Text)
+		} else {
+			p.Commands = append(p.Commands, command)
+		}
+
+		parser.ConsumeWhile(lex.Space)
+	}
+
+	if len(p.Commands) < 2 && !p.Negated {
+		return fmt.Errorf("Expected '|' in pipeline [bug?]")
+	}
+	return nil
+}
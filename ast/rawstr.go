@@ -11,3 +11,13 @@ func (s RawStr) IsStrPiece() {}
 func (s RawStr) Format(f fmt.State, c rune) {
 	fmt.Fprintf(f, string(s))
 }
+
+/* Text that was quoted, like the text in "..." or '...'. Unlike a RawStr, its
+ * characters are never special, so a '*' in it is never a pattern. */
+type QuotedStr string
+
+func (s QuotedStr) IsStrPiece() {}
+
+func (s QuotedStr) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "%q", string(s))
+}
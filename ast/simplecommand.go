@@ -7,7 +7,9 @@ import (
 )
 
 type SimpleCommand struct {
-	Redirects []*IoRedirect
+	// the NAME=value words before the command name
+	Assignments []*Assignment
+	Redirects   []*IoRedirect
 	// TODO: how do we store strings here?
 	Words []*Str
 }
@@ -23,18 +25,18 @@ func (s *SimpleCommand) IsCommand() {}
 
 func (s *SimpleCommand) Format(f fmt.State, c rune) {
 	fmt.Fprintf(f, "SimpleCommand[")
-	if len(s.Words) > 0 {
-		fmt.Fprintf(f, "%v", s.Words[0])
-		for _, word := range s.Words[1:] {
-			fmt.Fprintf(f, " %v", word)
-		}
+	sep := ""
+	for _, assignment := range s.Assignments {
+		fmt.Fprintf(f, "%v%v", sep, assignment)
+		sep = " "
+	}
+	for _, word := range s.Words {
+		fmt.Fprintf(f, "%v%v", sep, word)
+		sep = " "
 	}
 
-	if len(s.Redirects) > 0 {
-		fmt.Fprintf(f, "%v", s.Redirects[0])
-		for _, redirect := range s.Redirects {
-			fmt.Fprintf(f, " %v", redirect)
-		}
+	for _, redirect := range s.Redirects {
+		fmt.Fprintf(f, " %v", redirect)
 	}
 	fmt.Fprintf(f, "]")
 }
@@ -59,18 +61,6 @@ func (s *SimpleCommand) Parse(parser *Parser) error {
 
 		parser.ConsumeWhile(lex.Space)
 
-		if parser.Lexer.HasAnyToken(lex.DoubleQuote) {
-			ast_str := NewStr()
-			if err := ast_str.Parse(parser); err != nil {
-				return nil
-			} else {
-				s.Words = append(s.Words, ast_str)
-				continue
-			}
-		}
-
-		parser.ConsumeWhile(lex.Space)
-
 		if consumedAny, err := s.parseWordList(parser); err != nil {
 			return err
 		} else if consumedAny {
@@ -98,9 +88,24 @@ func (s *SimpleCommand) parseIoRedirect(parser *Parser) (bool, error) {
 func (s *SimpleCommand) parseWordList(parser *Parser) (bool, error) {
 	words_read := 0
 	for {
-		tok := parser.Lexer.Peek()
-		switch tok.Type {
-		case lex.Word, lex.Name, lex.Number, lex.Dollar, lex.DoubleQuote, lex.SingleQuote:
+		// a Number may be the IO_NUMBER of a redirect, like the 2 in "2>&1"
+		if parsed, err := s.parseIoRedirect(parser); err != nil {
+			return false, err
+		} else if parsed {
+			words_read++
+			continue
+		}
+
+		// an assignment is only an assignment before the command name. after
+		// that, it is an ordinary word, like the "a=b" in "echo a=b"
+		if len(s.Words) == 0 && parser.Lexer.HasAnyToken(lex.AssignmentWord) {
+			assignment := NewAssignment()
+			if err := assignment.Parse(parser); err != nil {
+				return false, err
+			}
+			s.Assignments = append(s.Assignments, assignment)
+			words_read++
+		} else if parser.HasWord() {
 			ast_str := NewStr()
 			if err := ast_str.Parse(parser); err != nil {
 				return false, err
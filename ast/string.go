@@ -8,8 +8,10 @@ import (
 
 // a Str is composed of a sequence of pieces. each piece can be:
 //
-//   1. RawStr (raw text)
+//   1. RawStr (raw text), or QuotedStr (quoted text)
 //   2. ParameterExpansion (a substitution)
+//   3. CommandSubstitution (the output of a command)
+//   4. ArithmeticExpansion (the value of an arithmetic expression)
 //
 type Str struct {
 	Pieces []StrPiece
@@ -33,8 +35,27 @@ func (s *Str) Format(f fmt.State, c rune) {
 	fmt.Fprintf(f, "]")
 }
 
+/* Parse a word, which is a sequence of segments with no space between them,
+ * like the '--prefix="$HOME"/opt' in
+ *
+ *	./configure --prefix="$HOME"/opt
+ *
+ * The pieces of every segment are added to this Str, so that the quoted and
+ * unquoted parts of the word can still be told apart when it is expanded. */
 func (s *Str) Parse(parser *Parser) error {
-	// a single-quoted string produces a single string segment
+	if !parser.HasWord() {
+		return fmt.Errorf("Failed to parse a Str [bug?]")
+	}
+	for parser.HasWord() {
+		if err := s.parseSegment(parser); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Parse one segment of a word, like a quoted string or an expansion */
+func (s *Str) parseSegment(parser *Parser) error {
 	tok := parser.Lexer.Peek()
 	switch tok.Type {
 	case lex.SingleQuote:
@@ -42,11 +63,18 @@ func (s *Str) Parse(parser *Parser) error {
 	case lex.DoubleQuote:
 		return s.parseDoubleQuotedString(parser)
 	case lex.Dollar:
-		return s.parseParamExpansion(parser)
-	case lex.Word, lex.Name, lex.Number:
+		return s.parseDollarExpansion(parser)
+	case lex.BackquotedCommand:
+		return s.parseCommandSubstitution(parser)
+	case lex.Word, lex.Name, lex.Number, lex.AssignmentWord:
 		parser.Lexer.Next()
 		s.Pieces = append(s.Pieces, RawStr(tok.Text))
 		return nil
+	case lex.EscapedChar:
+		// a character after a backslash is quoted, like the '*' in \*
+		parser.Lexer.Next()
+		s.Pieces = append(s.Pieces, QuotedStr(tok.Text))
+		return nil
 	default:
 		return fmt.Errorf("Failed to parse a Str [bug?]")
 	}
@@ -57,13 +85,13 @@ func (s *Str) parseSingleQuotedString(parser *Parser) error {
 		return fmt.Errorf("Expected single quote [bug!]")
 	}
 
-	// this is optional. we could have an empty string.
+	// the string may be empty, but it is still a piece of the word, so that
+	// a word like ''$X is never removed when $X is empty
+	piece := QuotedStr("")
 	if parser.Lexer.HasAnyToken(lex.StringSegment) {
-		tok := parser.Lexer.Next()
-		piece := RawStr(tok.Text)
-		s.Pieces = append(s.Pieces, piece)
-		return nil
+		piece = QuotedStr(parser.Lexer.Next().Text)
 	}
+	s.Pieces = append(s.Pieces, piece)
 
 	if parser.Lexer.HasAnyToken(lex.SingleQuote) {
 		parser.Lexer.Next()
@@ -73,6 +101,40 @@ func (s *Str) parseSingleQuotedString(parser *Parser) error {
 	return nil
 }
 
+/* Parse a parameter expansion, command substitution, or arithmetic expansion,
+ * depending on the token after the '$' */
+func (s *Str) parseDollarExpansion(parser *Parser) error {
+	dollar := parser.Lexer.Next()
+	next := parser.Lexer.Peek()
+	parser.Lexer.Unread(dollar)
+
+	switch next.Type {
+	case lex.LeftParen:
+		return s.parseCommandSubstitution(parser)
+	case lex.DoubleLeftParen:
+		return s.parseArithmeticExpansion(parser)
+	}
+	return s.parseParamExpansion(parser)
+}
+
+func (s *Str) parseArithmeticExpansion(parser *Parser) error {
+	ae := NewArithmeticExpansion()
+	if err := ae.Parse(parser); err != nil {
+		return err
+	}
+	s.Pieces = append(s.Pieces, StrPiece(ae))
+	return nil
+}
+
+func (s *Str) parseCommandSubstitution(parser *Parser) error {
+	cs := NewCommandSubstitution()
+	if err := cs.Parse(parser); err != nil {
+		return err
+	}
+	s.Pieces = append(s.Pieces, StrPiece(cs))
+	return nil
+}
+
 func (s *Str) parseParamExpansion(parser *Parser) error {
 	pe := &ParameterExpansion{}
 	if err := pe.Parse(parser); err != nil {
@@ -87,28 +149,56 @@ func (s *Str) parseDoubleQuotedString(parser *Parser) error {
 		return err
 	}
 
+	// like an empty single-quoted string, "" is a piece of the word
+	count := len(s.Pieces)
+	if err := s.parseStringContents(parser, lex.DoubleQuote); err != nil {
+		return err
+	} else if len(s.Pieces) == count {
+		s.Pieces = append(s.Pieces, QuotedStr(""))
+	}
+	return nil
+}
+
+/* Parse string segments and expansions until the end token is consumed */
+func (s *Str) parseStringContents(parser *Parser, end lex.TokenType) error {
 	for {
 		tok := parser.Lexer.Peek()
 		switch tok.Type {
+		case end:
+			parser.Lexer.Next()
+			return nil
 		case lex.EOF:
 			return fmt.Errorf("Unclosed string (expected \")")
 		case lex.ERROR:
 			return fmt.Errorf("%v", tok)
-		case lex.DoubleQuote:
-			parser.Lexer.Next()
-			return nil
 		case lex.StringSegment:
 			parser.Lexer.Next()
-			s.Pieces = append(s.Pieces, RawStr(tok.Text))
+			s.Pieces = append(s.Pieces, QuotedStr(tok.Text))
 		case lex.Dollar:
-			if err := s.parseParamExpansion(parser); err != nil {
+			if err := s.parseDollarExpansion(parser); err != nil {
+				return err
+			}
+			markQuoted(s.Pieces[len(s.Pieces)-1])
+		case lex.BackquotedCommand:
+			if err := s.parseCommandSubstitution(parser); err != nil {
 				return err
 			}
+			markQuoted(s.Pieces[len(s.Pieces)-1])
 		default:
 			return fmt.Errorf("Unexpected token in string: %v", tok)
 		}
 	}
+}
 
-	return nil
-
+/* Mark an expansion within a string as quoted, so that its result is not
+ * split into fields */
+func markQuoted(piece StrPiece) {
+	switch p := piece.(type) {
+	case *ParameterExpansion:
+		p.Quoted = true
+	case *CommandSubstitution:
+		p.Quoted = true
+	case *ArithmeticExpansion:
+		p.Quoted = true
+	}
 }
@@ -0,0 +1,55 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* ( <body> )
+ *
+ * A subshell runs its body in a copy of the shell, so that changes to
+ * variables, functions and the working directory do not affect the shell. */
+type Subshell struct {
+	Body      []Node
+	Redirects []*IoRedirect
+}
+
+func NewSubshell() *Subshell {
+	return &Subshell{
+		Body:      []Node{},
+		Redirects: []*IoRedirect{},
+	}
+}
+
+func (s *Subshell) IsCommand() {}
+
+func (s *Subshell) Format(f fmt.State, _ rune) {
+	fmt.Fprintf(f, "Subshell%v", s.Body)
+	for _, redirect := range s.Redirects {
+		fmt.Fprintf(f, " %v", redirect)
+	}
+}
+
+func (s *Subshell) Parse(parser *Parser) error {
+	// "("
+	if _, err := parser.ConsumeToken(lex.LeftParen, nil); err != nil {
+		return err
+	}
+
+	if nodes, err := parser.ParseUntil(lex.RightParen); err != nil {
+		return err
+	} else if len(nodes) == 0 {
+		return fmt.Errorf("Empty (...) block")
+	} else {
+		s.Body = nodes
+	}
+	parser.Lexer.Next()
+
+	if redirects, err := parseRedirects(parser); err != nil {
+		return err
+	} else {
+		s.Redirects = redirects
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* while <condition> do <body> done
+ *
+ * The body runs while the exit status of the condition is zero. */
+type WhileClause struct {
+	Condition []Node
+	DoClause  *DoClause
+	Redirects []*IoRedirect
+}
+
+/* until <condition> do <body> done
+ *
+ * The body runs until the exit status of the condition is zero. */
+type UntilClause struct {
+	Condition []Node
+	DoClause  *DoClause
+	Redirects []*IoRedirect
+}
+
+func NewWhileClause() *WhileClause {
+	return &WhileClause{
+		Condition: []Node{},
+		DoClause:  NewDoClause(),
+		Redirects: []*IoRedirect{},
+	}
+}
+
+func NewUntilClause() *UntilClause {
+	return &UntilClause{
+		Condition: []Node{},
+		DoClause:  NewDoClause(),
+		Redirects: []*IoRedirect{},
+	}
+}
+
+func (w *WhileClause) IsCommand() {}
+func (u *UntilClause) IsCommand() {}
+
+func (w *WhileClause) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "While[%v %v]", w.Condition, w.DoClause)
+	for _, redirect := range w.Redirects {
+		fmt.Fprintf(f, " %v", redirect)
+	}
+}
+
+func (u *UntilClause) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "Until[%v %v]", u.Condition, u.DoClause)
+	for _, redirect := range u.Redirects {
+		fmt.Fprintf(f, " %v", redirect)
+	}
+}
+
+func (w *WhileClause) Parse(parser *Parser) error {
+	return parseLoop(parser, lex.While, &w.Condition, w.DoClause, &w.Redirects)
+}
+
+func (u *UntilClause) Parse(parser *Parser) error {
+	return parseLoop(parser, lex.Until, &u.Condition, u.DoClause, &u.Redirects)
+}
+
+/* Parse a "while" or "until" loop, which differ only in the first keyword */
+func parseLoop(parser *Parser, keyword lex.TokenType, condition *[]Node,
+	do_clause *DoClause, redirects *[]*IoRedirect) error {
+
+	if _, err := parser.ConsumeToken(keyword, nil); err != nil {
+		return err
+	}
+
+	if nodes, err := parser.ParseUntil(lex.Do); err != nil {
+		return err
+	} else if len(nodes) == 0 {
+		return fmt.Errorf("Syntax error near \"do\" (expected a condition)")
+	} else {
+		*condition = nodes
+	}
+
+	// "do ... done"
+	if err := do_clause.Parse(parser); err != nil {
+		return err
+	}
+
+	if nodes, err := parseRedirects(parser); err != nil {
+		return err
+	} else {
+		*redirects = nodes
+	}
+	return nil
+}
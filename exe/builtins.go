@@ -0,0 +1,360 @@
+package exe
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pglass/pshhh/lex"
+)
+
+/* A builtin runs within the interpreter, with the interpreter's files already
+ * redirected for the command. It returns the exit status of the command. */
+type builtinFunc func(i *Interpreter, args []string) (int, error)
+
+var builtins map[string]builtinFunc
+
+func init() {
+	builtins = map[string]builtinFunc{
+		"break":    builtinBreak,
+		"cd":       builtinCd,
+		"continue": builtinContinue,
+		"exit":     builtinExit,
+		"export":   builtinExport,
+		"local":    builtinLocal,
+		"readonly": builtinReadonly,
+		"return":   builtinReturn,
+		"set":      builtinSet,
+		"shift":    builtinShift,
+		"unset":    builtinUnset,
+	}
+}
+
+/* The special builtins are found before functions, so a function cannot
+ * replace them. Other builtins, like cd, are found after functions. */
+var specialBuiltins = map[string]bool{
+	"break":    true,
+	"continue": true,
+	"exit":     true,
+	"export":   true,
+	"readonly": true,
+	"return":   true,
+	"set":      true,
+	"shift":    true,
+	"unset":    true,
+}
+
+/* break [n] -- exit from the n innermost loops */
+func builtinBreak(i *Interpreter, args []string) (int, error) {
+	count, status := i.loopCount(args)
+	if count == 0 {
+		return status, nil
+	}
+	return 0, BreakError{Count: count}
+}
+
+/* continue [n] -- continue the n'th innermost loop */
+func builtinContinue(i *Interpreter, args []string) (int, error) {
+	count, status := i.loopCount(args)
+	if count == 0 {
+		return status, nil
+	}
+	return 0, ContinueError{Count: count}
+}
+
+/* Parse the loop count argument of "break" or "continue". Returns zero and
+ * the exit status if the command should do nothing. */
+func (i *Interpreter) loopCount(args []string) (int, int) {
+	count := 1
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			i.printError("%v: %v: loop count out of range", args[0], args[1])
+			return 0, 1
+		}
+		count = n
+	}
+
+	if i.loopDepth == 0 {
+		i.printError("%v: only meaningful in a loop", args[0])
+		return 0, 0
+	}
+	if count > i.loopDepth {
+		count = i.loopDepth
+	}
+	return count, 0
+}
+
+/* cd [dir] -- change the working directory of the shell. With no argument,
+ * change to $HOME. "cd -" changes to $OLDPWD and prints the new directory. */
+func builtinCd(i *Interpreter, args []string) (int, error) {
+	var dir string
+	if len(args) > 2 {
+		i.printError("%v: too many arguments", args[0])
+		return 1, nil
+	} else if len(args) == 1 {
+		if is_set, home := i.FetchVar("HOME"); !is_set {
+			i.printError("%v: HOME not set", args[0])
+			return 1, nil
+		} else {
+			dir = home
+		}
+	} else if args[1] == "-" {
+		if is_set, old := i.FetchVar("OLDPWD"); !is_set {
+			i.printError("%v: OLDPWD not set", args[0])
+			return 1, nil
+		} else {
+			dir = old
+		}
+	} else {
+		dir = args[1]
+	}
+
+	path := filepath.Clean(i.resolvePath(dir))
+	if info, err := os.Stat(path); err != nil {
+		if e, ok := err.(*os.PathError); ok {
+			err = e.Err
+		}
+		i.printError("%v: %v: %v", args[0], dir, err)
+		return 1, nil
+	} else if !info.IsDir() {
+		i.printError("%v: %v: not a directory", args[0], dir)
+		return 1, nil
+	}
+
+	old_dir := i.Dir
+	i.Dir = path
+	for _, err := range []error{i.SetVar("OLDPWD", old_dir), i.SetVar("PWD", path)} {
+		if err != nil {
+			i.printError("%v: %v", args[0], err)
+			return 1, nil
+		}
+	}
+
+	if len(args) > 1 && args[1] == "-" && len(i.Files) > 1 && i.Files[1] != nil {
+		fmt.Fprintln(i.Files[1], path)
+	}
+	return 0, nil
+}
+
+/* exit [n] -- exit the shell with status n, or with the status of the last
+ * command */
+func builtinExit(i *Interpreter, args []string) (int, error) {
+	status := i.LastStatus
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			i.printError("%v: %v: numeric argument required", args[0], args[1])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	return status, ExitError{ExitCode: status}
+}
+
+/* export [-p] [name[=value]...] -- export variables to the environment of
+ * the commands we run. With no names, or with -p, print the exported
+ * variables in a form that can be run to export them again. */
+func builtinExport(i *Interpreter, args []string) (int, error) {
+	return i.setAttribute(args, "export", func(v *Variable) *bool { return &v.Exported })
+}
+
+/* readonly [-p] [name[=value]...] -- make variables readonly. With no names,
+ * or with -p, print the readonly variables. */
+func builtinReadonly(i *Interpreter, args []string) (int, error) {
+	return i.setAttribute(args, "readonly", func(v *Variable) *bool { return &v.ReadOnly })
+}
+
+/* Assign the "name=value" arguments of export or readonly, and set the
+ * attribute of each named variable. With no names, print the variables that
+ * have the attribute. */
+func (i *Interpreter) setAttribute(args []string, command string, attribute func(*Variable) *bool) (int, error) {
+	names := args[1:]
+	if len(names) > 0 && names[0] == "-p" {
+		names = names[1:]
+	}
+
+	if len(names) == 0 {
+		for _, name := range i.Vars.Names() {
+			if *attribute(i.Vars.Get(name)) {
+				i.printVariable(command, name)
+			}
+		}
+		return 0, nil
+	}
+
+	status := 0
+	for _, arg := range names {
+		parts := strings.SplitN(arg, "=", 2)
+		name := parts[0]
+		if !lex.IsName(name) {
+			i.printError("%v: `%v': not a valid identifier", args[0], arg)
+			status = 1
+			continue
+		}
+
+		if len(parts) > 1 {
+			if err := i.SetVar(name, parts[1]); err != nil {
+				i.printError("%v", err)
+				status = 1
+				continue
+			}
+		}
+		*attribute(i.Vars.Ensure(name)) = true
+	}
+	return status, nil
+}
+
+/* Print a command, like `export NAME="value"`, that recreates the variable.
+ * With no command, this prints only the assignment. */
+func (i *Interpreter) printVariable(command, name string) {
+	if len(i.Files) < 2 || i.Files[1] == nil {
+		return
+	}
+	if command != "" {
+		command += " "
+	}
+
+	variable := i.Vars.Get(name)
+	if !variable.IsSet {
+		fmt.Fprintf(i.Files[1], "%v%v\n", command, name)
+		return
+	}
+
+	// within double quotes, only these characters need to be escaped
+	value := variable.Value
+	for _, c := range []string{"\\", "\"", "$", "`"} {
+		value = strings.Replace(value, c, "\\"+c, -1)
+	}
+	fmt.Fprintf(i.Files[1], "%v%v=\"%v\"\n", command, name, value)
+}
+
+/* set [--] [arg...] -- set the positional parameters to the arguments. With
+ * no arguments, print the shell's variables. */
+func builtinSet(i *Interpreter, args []string) (int, error) {
+	if len(args) == 1 {
+		for _, name := range i.Vars.Names() {
+			if i.Vars.Get(name).IsSet {
+				i.printVariable("", name)
+			}
+		}
+		return 0, nil
+	}
+
+	params := args[1:]
+	if params[0] == "--" {
+		params = params[1:]
+	} else if strings.HasPrefix(params[0], "-") || strings.HasPrefix(params[0], "+") {
+		i.printError("%v: %v: invalid option", args[0], params[0])
+		return 2, nil
+	}
+	i.Args = append([]string{}, params...)
+	return 0, nil
+}
+
+/* shift [n] -- remove the first n positional parameters, so $n+1 becomes $1 */
+func builtinShift(i *Interpreter, args []string) (int, error) {
+	count := 1
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			i.printError("%v: %v: numeric argument required", args[0], args[1])
+			return 1, nil
+		}
+		count = n
+	}
+
+	if count < 0 || count > len(i.Args) {
+		i.printError("%v: %v: shift count out of range", args[0], count)
+		return 1, nil
+	}
+	i.Args = i.Args[count:]
+	return 0, nil
+}
+
+/* unset [-f|-v] name... -- unset variables, or functions with -f */
+func builtinUnset(i *Interpreter, args []string) (int, error) {
+	names := args[1:]
+	functions := false
+	if len(names) > 0 && (names[0] == "-f" || names[0] == "-v") {
+		functions = names[0] == "-f"
+		names = names[1:]
+	}
+
+	status := 0
+	for _, name := range names {
+		if !lex.IsName(name) {
+			i.printError("%v: `%v': not a valid identifier", args[0], name)
+			status = 1
+		} else if functions {
+			delete(i.functions, name)
+		} else if err := i.UnsetVar(name); err != nil {
+			i.printError("%v: %v", args[0], err)
+			status = 1
+		}
+	}
+	return status, nil
+}
+
+/* local [-i] name[=value]... -- make variables local to the current function.
+ * The variables are discarded when the function returns. With -i, the
+ * variables are integer variables. */
+func builtinLocal(i *Interpreter, args []string) (int, error) {
+	if i.functionDepth == 0 {
+		i.printError("%v: can only be used in a function", args[0])
+		return 1, nil
+	}
+
+	names := args[1:]
+	integer := false
+	if len(names) > 0 && names[0] == "-i" {
+		integer = true
+		names = names[1:]
+	}
+
+	status := 0
+	for _, arg := range names {
+		parts := strings.SplitN(arg, "=", 2)
+		name := parts[0]
+		if !lex.IsName(name) {
+			i.printError("%v: `%v': not a valid identifier", args[0], arg)
+			status = 1
+			continue
+		} else if err := i.checkWritable(name); err != nil {
+			i.printError("%v: %v", args[0], err)
+			status = 1
+			continue
+		}
+
+		i.Vars.Declare(name).Integer = integer
+		if len(parts) > 1 {
+			if err := i.SetVar(name, parts[1]); err != nil {
+				i.printError("%v: %v", args[0], err)
+				status = 1
+			}
+		}
+	}
+	return status, nil
+}
+
+/* return [n] -- return from a function with status n, or with the status of
+ * the last command */
+func builtinReturn(i *Interpreter, args []string) (int, error) {
+	if i.functionDepth == 0 {
+		i.printError("%v: can only `return' from a function", args[0])
+		return 1, nil
+	}
+
+	status := i.LastStatus
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			i.printError("%v: %v: numeric argument required", args[0], args[1])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	return status, ReturnError{Status: status}
+}
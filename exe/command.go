@@ -13,12 +13,22 @@ type PshProc struct {
 	Args         []string
 	ProcAttr     *syscall.ProcAttr
 	IsBackground bool
+
+	// set after waiting on a foreground process
+	WaitStatus syscall.WaitStatus
 }
 
-func NewPshProc(args []string, env []string) (*PshProc, error) {
-	work_dir, err := os.Getwd()
-	if err != nil {
-		return nil, err
+/* Create a process that runs in the directory work_dir. The child's file
+ * descriptors are taken from files, so files[0] becomes the child's stdin,
+ * files[1] its stdout, and so on. A nil entry means that descriptor is closed
+ * in the child. */
+func NewPshProc(args []string, env []string, work_dir string, files []*os.File) (*PshProc, error) {
+	if work_dir == "" {
+		if dir, err := os.Getwd(); err != nil {
+			return nil, err
+		} else {
+			work_dir = dir
+		}
 	}
 
 	proc := &PshProc{
@@ -27,12 +37,25 @@ func NewPshProc(args []string, env []string) (*PshProc, error) {
 		ProcAttr: &syscall.ProcAttr{
 			Dir:   work_dir,
 			Env:   env,
-			Files: []uintptr{0, 1, 2},
+			Files: fileDescriptors(files),
 		},
 	}
 	return proc, nil
 }
 
+func fileDescriptors(files []*os.File) []uintptr {
+	fds := make([]uintptr, len(files))
+	for fd, file := range files {
+		if file == nil {
+			// ForkExec closes any descriptor set to -1
+			fds[fd] = ^uintptr(0)
+		} else {
+			fds[fd] = file.Fd()
+		}
+	}
+	return fds
+}
+
 func (c *PshProc) ForkExec() (int, error) {
 	command_name := c.PathLookup(c.Name)
 
@@ -53,7 +76,9 @@ func (c *PshProc) ForkExec() (int, error) {
 		rusage := syscall.Rusage{}
 
 		// https://linux.die.net/man/2/wait
-		wait_opts := syscall.WEXITED | syscall.WSTOPPED
+		// note: WEXITED is only valid for waitid(). wait4() fails with
+		// EINVAL if it is given, and then we would never wait at all.
+		wait_opts := 0
 
 		// wait for the process to exit
 		wpid, err := syscall.Wait4(pid, &waitstatus, wait_opts, &rusage)
@@ -61,12 +86,23 @@ func (c *PshProc) ForkExec() (int, error) {
 			log.Printf("%v", err)
 		} else if wpid != pid {
 			log.Printf("Wait4 return non-matching pid %v (expected %v). Did process %v exit?", wpid, pid, pid)
+		} else {
+			c.WaitStatus = waitstatus
 		}
 	}
 
 	return pid, err
 }
 
+/* The exit status of the process, after waiting on it. The status of a
+ * process killed by a signal is 128 plus the signal number. */
+func (c *PshProc) ExitStatus() int {
+	if c.WaitStatus.Signaled() {
+		return 128 + int(c.WaitStatus.Signal())
+	}
+	return c.WaitStatus.ExitStatus()
+}
+
 /* Lookup the name on the path. This works as follows
  *   - If name is a path (starts with "/" or "./")
  *   - Else, find the first directory, dir, in the PATH variable
@@ -89,7 +125,12 @@ func (c *PshProc) PathLookup(name string) string {
 		}
 
 		check_file := path.Join(dir, name)
-		if _, err := os.Stat(check_file); err == nil {
+		// a relative directory in PATH is relative to the child's directory
+		stat_file := check_file
+		if !path.IsAbs(stat_file) {
+			stat_file = path.Join(c.ProcAttr.Dir, stat_file)
+		}
+		if _, err := os.Stat(stat_file); err == nil {
 			log.Printf("PathLookup: Found %q at %q in dir %q", name, check_file, dir)
 			return check_file
 		} else {
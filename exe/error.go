@@ -1,6 +1,47 @@
 package exe
 
+import (
+	"fmt"
+)
+
+/* Returned to exit the shell with ExitCode. The error is the message to
+ * print on exit, if any. */
 type ExitError struct {
 	error
 	ExitCode int
 }
+
+/* Returned by the "break" builtin to exit the Count innermost loops */
+type BreakError struct {
+	Count int
+}
+
+/* Returned by the "continue" builtin to skip to the next iteration of the
+ * Count'th innermost loop */
+type ContinueError struct {
+	Count int
+}
+
+/* Returned by the "return" builtin to return from the current function */
+type ReturnError struct {
+	Status int
+}
+
+func (e ExitError) Error() string {
+	if e.error == nil {
+		return ""
+	}
+	return e.error.Error()
+}
+
+func (e BreakError) Error() string {
+	return fmt.Sprintf("break %v", e.Count)
+}
+
+func (e ContinueError) Error() string {
+	return fmt.Sprintf("continue %v", e.Count)
+}
+
+func (e ReturnError) Error() string {
+	return fmt.Sprintf("return %v", e.Status)
+}
@@ -0,0 +1,123 @@
+package exe
+
+import (
+	"bytes"
+	"strings"
+)
+
+// the whitespace characters that are treated specially when they are in IFS
+const IFS_WHITESPACE = " \t\n"
+
+/* A field of an expanded word. The pattern is the text of the field with
+ * the quoted pattern characters escaped by a backslash, and is_pattern is
+ * true if the field has any unquoted '*', '?' or '[', so that it is expanded
+ * to the pathnames it matches. */
+type field struct {
+	text       string
+	pattern    string
+	is_pattern bool
+}
+
+/* Splits the expansions of a word into fields. The text of unquoted
+ * expansions is split on the characters in IFS, while other text is added to
+ * the current field as it is:
+ *
+ *   - IFS whitespace at the start or end of the text is ignored, and a
+ *     sequence of IFS whitespace separates two fields.
+ *   - Every other IFS character separates two fields, together with any IFS
+ *     whitespace around it. So two of them in a row give an empty field.
+ *
+ * A word that is only unquoted expansions with empty results gives no fields
+ * at all, but quoted text always gives a field, even if it is empty.
+ */
+type fieldSplitter struct {
+	ifs     string
+	fields  []field
+	buffer  bytes.Buffer
+	pattern bytes.Buffer
+
+	// true if the current field has any unquoted pattern characters
+	is_pattern bool
+
+	// true if a field has been started, though it may still be empty
+	in_field bool
+	// true if IFS whitespace ended the last field, so that an IFS character
+	// right after it does not end another field
+	after_space bool
+}
+
+func newFieldSplitter(ifs string) *fieldSplitter {
+	return &fieldSplitter{ifs: ifs, fields: []field{}}
+}
+
+/* Add the text to the current field. If split is true, the text is split on
+ * the characters in IFS. If quoted is true, any pattern characters in the
+ * text match literally. */
+func (f *fieldSplitter) add(text string, split, quoted bool) {
+	if !split {
+		for _, c := range text {
+			f.write(c, quoted)
+		}
+		f.in_field = true
+		f.after_space = false
+		return
+	}
+
+	for _, c := range text {
+		if !strings.ContainsRune(f.ifs, c) {
+			f.write(c, quoted)
+			f.in_field = true
+			f.after_space = false
+		} else if strings.ContainsRune(IFS_WHITESPACE, c) {
+			if f.in_field {
+				f.endField()
+				f.after_space = true
+			}
+		} else if f.after_space {
+			f.after_space = false
+		} else {
+			f.pushField()
+		}
+	}
+}
+
+/* Write a character to the current field and its pattern */
+func (f *fieldSplitter) write(c rune, quoted bool) {
+	f.buffer.WriteRune(c)
+	if !strings.ContainsRune("*?[]\\", c) {
+		f.pattern.WriteRune(c)
+	} else if quoted {
+		f.pattern.WriteRune('\\')
+		f.pattern.WriteRune(c)
+	} else {
+		f.pattern.WriteRune(c)
+		f.is_pattern = f.is_pattern || strings.ContainsRune("*?[", c)
+	}
+}
+
+/* Add the current field to the fields, and start an empty field */
+func (f *fieldSplitter) pushField() {
+	f.fields = append(f.fields, field{
+		text:       f.buffer.String(),
+		pattern:    f.pattern.String(),
+		is_pattern: f.is_pattern,
+	})
+	f.buffer.Reset()
+	f.pattern.Reset()
+	f.is_pattern = false
+	f.in_field = false
+}
+
+/* End the current field, if one has been started */
+func (f *fieldSplitter) endField() {
+	if f.in_field {
+		f.pushField()
+	}
+	f.after_space = false
+}
+
+/* End the current field and return all of the fields */
+func (f *fieldSplitter) Fields() []field {
+	f.endField()
+	return f.fields
+}
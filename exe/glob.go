@@ -0,0 +1,100 @@
+package exe
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/* Expand the pattern to the paths that it matches, in sorted order. A
+ * relative pattern is matched relative to the directory dir, but the paths
+ * are relative like the pattern. Each component of the pattern between
+ * slashes is matched against the names in a directory using MatchPattern,
+ * except that a name starting with '.' is only matched by a component that
+ * starts with '.'. Returns nil if nothing matches. */
+func Glob(pattern, dir string) []string {
+	components := strings.Split(pattern, "/")
+	if components[0] == "" {
+		// an absolute pattern, like "/tmp/*"
+		return globPaths(dir, "/", components[1:])
+	}
+	return globPaths(dir, "", components)
+}
+
+/* Returns true if the text has any unescaped '*', '?' or '[' */
+func HasGlobChars(text string) bool {
+	for j := 0; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+/* Match the components of a pattern against the paths under prefix, which is
+ * empty or ends with '/' */
+func globPaths(dir, prefix string, components []string) []string {
+	component, rest := components[0], components[1:]
+
+	var names []string
+	if !HasGlobChars(component) {
+		names = []string{unescapePattern(component)}
+	} else {
+		file, err := os.Open(inDir(dir, prefix))
+		if err != nil {
+			return nil
+		}
+		entries, err := file.Readdirnames(-1)
+		file.Close()
+		if err != nil {
+			return nil
+		}
+
+		hidden := strings.HasPrefix(component, ".") || strings.HasPrefix(component, "\\.")
+		for _, name := range entries {
+			if strings.HasPrefix(name, ".") && !hidden {
+				continue
+			}
+			if MatchPattern(component, name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+
+	var result []string
+	for _, name := range names {
+		path := prefix + name
+		if len(rest) > 0 {
+			result = append(result, globPaths(dir, path+"/", rest)...)
+		} else if _, err := os.Lstat(inDir(dir, path)); err == nil {
+			result = append(result, path)
+		}
+	}
+	return result
+}
+
+/* Return the path relative to dir. Unlike filepath.Join, this keeps a
+ * trailing slash, which only a directory can have. */
+func inDir(dir, path string) string {
+	if filepath.IsAbs(path) || dir == "" {
+		return path
+	}
+	return dir + "/" + path
+}
+
+/* Remove the backslashes that escape characters in a pattern */
+func unescapePattern(pattern string) string {
+	var result []byte
+	for j := 0; j < len(pattern); j++ {
+		if pattern[j] == '\\' && j+1 < len(pattern) {
+			j++
+		}
+		result = append(result, pattern[j])
+	}
+	return string(result)
+}
@@ -3,21 +3,169 @@ package exe
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 
+	"github.com/pglass/pshhh/arith"
 	"github.com/pglass/pshhh/ast"
 	"github.com/pglass/pshhh/lex"
 )
 
 type Interpreter struct {
 	Debug bool
-	Env   []string
+
+	// The shell's variables. The exported variables are the environment of
+	// the commands run by the interpreter.
+	Vars *Variables
+
+	// The exit status of the last command run
+	LastStatus int
+
+	// The name of the shell or script, $0
+	Name string
+
+	// The positional parameters, $1 $2 ...
+	Args []string
+
+	// The option flags of the shell, $-
+	Flags string
+
+	// The working directory of the shell. Commands run by the interpreter
+	// start in this directory.
+	Dir string
+
+	// The open files of the shell, indexed by file descriptor. Commands run
+	// by the interpreter inherit these files.
+	Files []*os.File
+
+	// the number of loops we are currently inside
+	loopDepth int
+
+	// the number of function calls we are currently inside
+	functionDepth int
+
+	// true if a command substitution has run while expanding the current
+	// simple command. a command with no command name, like "x=$(false)",
+	// has the status of its last command substitution.
+	substituted bool
+
+	// the functions that have been defined, by name
+	functions map[string]*ast.FunctionDefinition
+
+	// the files opened by the redirects of the commands we are running
+	// inside, like the "> out" in "{ f & } > out". a background job holds
+	// these until it finishes.
+	redirected []*openedFiles
+
+	// the running background jobs. this is shared with the copies of the
+	// interpreter, so the shell waits for the jobs they start too.
+	jobs *sync.WaitGroup
+	// the pid of the last job started in the background, for $!, or zero
+	lastJob int
 }
 
 func NewInterpreter() *Interpreter {
+	// if this fails, commands use the working directory of psh itself
+	dir, _ := os.Getwd()
+
+	return &Interpreter{
+		Debug:     false,
+		Vars:      NewVariables(),
+		Name:      "psh",
+		Dir:       dir,
+		Files:     []*os.File{os.Stdin, os.Stdout, os.Stderr},
+		functions: map[string]*ast.FunctionDefinition{},
+		jobs:      &sync.WaitGroup{},
+	}
+}
+
+// the PATH to use when the environment has none
+const DEFAULT_PATH = "/usr/local/bin:/usr/bin:/bin"
+
+// the IFS to use when the environment has none
+const DEFAULT_IFS = " \t\n"
+
+/* Import the "<key>=<value>" items of the environment as exported variables,
+ * and set the variables that a shell sets on startup:
+ *
+ *	PWD	the working directory
+ *	PPID	the process id of the shell's parent (readonly)
+ *	SHLVL	one more than the inherited value, counting nested shells
+ *	IFS	the field separators, if not inherited
+ *	PATH	a default search path, if not inherited
+ *
+ * If export is true, PWD, SHLVL and the default PATH are exported. The default
+ * PATH is exported because commands are found using the PATH of their
+ * environment. Otherwise, like for "psh -i", these are only shell variables,
+ * and the environment of a command has just the variables it is given. */
+func (i *Interpreter) InitVars(env []string, export bool) {
+	i.Vars.Import(env)
+
+	i.Vars.Set("PWD", i.Dir)
+	if export {
+		i.Vars.Ensure("PWD").Exported = true
+	}
+
+	i.Vars.Set("PPID", strconv.Itoa(os.Getppid()))
+	i.Vars.Ensure("PPID").ReadOnly = true
+
+	level := 0
+	if is_set, value := i.FetchVar("SHLVL"); is_set {
+		level, _ = strconv.Atoi(strings.TrimSpace(value))
+	}
+	i.Vars.Set("SHLVL", strconv.Itoa(level+1))
+	if export {
+		i.Vars.Ensure("SHLVL").Exported = true
+	}
+
+	if is_set, _ := i.FetchVar("IFS"); !is_set {
+		i.Vars.Set("IFS", DEFAULT_IFS)
+	}
+	if is_set, _ := i.FetchVar("PATH"); !is_set {
+		i.Vars.Set("PATH", DEFAULT_PATH)
+		if export {
+			i.Vars.Ensure("PATH").Exported = true
+		}
+	}
+}
+
+/* Return a copy of the interpreter that can run concurrently with this one,
+ * without modifying this interpreter's state. */
+func (i *Interpreter) clone() *Interpreter {
+	args := make([]string, len(i.Args))
+	copy(args, i.Args)
+
+	files := make([]*os.File, len(i.Files))
+	copy(files, i.Files)
+
+	functions := map[string]*ast.FunctionDefinition{}
+	for name, fn := range i.functions {
+		functions[name] = fn
+	}
+
+	redirected := make([]*openedFiles, len(i.redirected))
+	copy(redirected, i.redirected)
+
 	return &Interpreter{
-		Debug: false,
+		Debug:         i.Debug,
+		Vars:          i.Vars.Copy(),
+		LastStatus:    i.LastStatus,
+		Name:          i.Name,
+		Args:          args,
+		Flags:         i.Flags,
+		Dir:           i.Dir,
+		Files:         files,
+		functionDepth: i.functionDepth,
+		functions:     functions,
+		redirected:    redirected,
+		jobs:          i.jobs,
+		lastJob:       i.lastJob,
 	}
 }
 
@@ -27,37 +175,8 @@ func (i *Interpreter) Interpret(node ast.Node) error {
 		return i.interpretGenericNode(n)
 	case *ast.CommandList:
 		return i.interpretCommandList(n)
-	case *ast.Str:
-		// a string could be a param expansion that resolves to a program. for example, if you do
-		//
-		//     $ export FOO='echo wumbo'
-		//	   $ $FOO
-		//	   wumbo
-		//
-		// TODO: figure out how this should work. In bash, there seem to be some limitations around
-		// what how "programs stored in environment variables" get executed. Are these limited to
-		// single commands? Are some limitations for security reasons?
-		//
-		// For example,
-		//
-		//     bash> FOO='echo wumbo; echo mini'; $FOO
-		//     wumbo; echo mini
-		//
-		//     bash> export Y=thisisy
-		//     bash> FOO='echo $Y'; $FOO
-		//     $Y
-		//
-		if text, err := i.interpretString(n); err != nil {
-			return err
-		} else {
-			lexer := lex.NewLexer(text)
-			parser := ast.NewParser(lexer)
-			if root, err := parser.Parse(); err != nil {
-				return err
-			} else {
-				return i.Interpret(root)
-			}
-		}
+	case ast.Command:
+		return i.interpretCommand(n, false)
 	}
 	return fmt.Errorf("ERROR: Unhandled node %v\n", node)
 }
@@ -75,83 +194,821 @@ func (i *Interpreter) interpretGenericNode(node *ast.GenericNode) error {
 func (i *Interpreter) interpretCommandList(node *ast.CommandList) error {
 	log.Printf("Interpret CommandList: %v", node)
 	for j, command := range node.Commands {
-		var proc *PshProc
-		var err error
+		// a command is backgrounded if followed by '&'
+		// todo: the parser should make this easier for us
+		is_background := j < len(node.Separators) &&
+			node.Separators[j].Type == lex.Ampersand
 
-		switch n := command.(type) {
-		case *ast.SimpleCommand:
-			if proc, err = i.interpretSimpleCommand(n); err != nil {
+		if err := i.interpretCommand(command, is_background); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Run the commands of an and-or list from left to right. The command after
+ * "&&" runs only if the status so far is zero, and the command after "||"
+ * runs only if the status so far is non-zero. */
+func (i *Interpreter) interpretAndOrClause(node *ast.AndOrClause) error {
+	log.Printf("Interpret AndOrClause: %v", node)
+
+	if err := i.Interpret(node.Left); err != nil {
+		return err
+	}
+
+	for clause := node; clause.Operator != nil && clause.AndOrClause != nil; clause = clause.AndOrClause {
+		is_and := clause.Operator.Type == lex.AndIf
+		if is_and == (i.LastStatus == 0) {
+			if err := i.Interpret(clause.AndOrClause.Left); err != nil {
 				return err
 			}
-		default:
-			return fmt.Errorf("Unhandled command in CommandList: %v", n)
 		}
+	}
+	return nil
+}
 
-		// a command is backgrounded if followed by '&'
-		// todo: the parser should make this easier for us
-		proc.IsBackground = j < len(node.Separators) &&
-			node.Separators[j].Type == lex.Ampersand
+func (i *Interpreter) interpretCommand(command ast.Command, is_background bool) error {
+	switch n := command.(type) {
+	case *ast.SimpleCommand:
+		return i.interpretSimpleCommand(n, is_background)
+	case *ast.Pipeline:
+		return i.interpretPipeline(n, is_background)
+	}
+
+	// other commands, like compound commands and and-or lists, are run in the
+	// background by a copy of the interpreter
+	if is_background {
+		subshell := i.clone()
+		i.startSubshellJob(subshell, func() error {
+			return subshell.interpretCompoundCommand(command)
+		})
+		return nil
+	}
+	return i.interpretCompoundCommand(command)
+}
+
+func (i *Interpreter) interpretCompoundCommand(command ast.Command) error {
+	switch n := command.(type) {
+	case *ast.IfClause:
+		return i.withRedirects(n.Redirects, func() error {
+			return i.interpretIfClause(n)
+		})
+	case *ast.WhileClause:
+		return i.withRedirects(n.Redirects, func() error {
+			return i.interpretLoop(n.Condition, n.DoClause, false)
+		})
+	case *ast.UntilClause:
+		return i.withRedirects(n.Redirects, func() error {
+			return i.interpretLoop(n.Condition, n.DoClause, true)
+		})
+	case *ast.ForClause:
+		return i.withRedirects(n.Redirects, func() error {
+			return i.interpretForClause(n)
+		})
+	case *ast.CaseClause:
+		return i.withRedirects(n.Redirects, func() error {
+			return i.interpretCaseClause(n)
+		})
+	case *ast.Subshell:
+		return i.interpretSubshell(n)
+	case *ast.AndOrClause:
+		return i.interpretAndOrClause(n)
+	case *ast.BraceGroup:
+		return i.withRedirects(n.Redirects, func() error {
+			return i.interpretNodes(n.Body)
+		})
+	case *ast.FunctionDefinition:
+		log.Printf("Define function: %v", n)
+		i.functions[n.Name.Text] = n
+		i.LastStatus = 0
+		return nil
+	default:
+		return fmt.Errorf("Unhandled command in CommandList: %v", n)
+	}
+}
+
+/* Run the body of the subshell in a copy of the interpreter, so that any
+ * changes to variables, functions, the working directory or open files are
+ * discarded when the subshell finishes. */
+func (i *Interpreter) interpretSubshell(node *ast.Subshell) error {
+	log.Printf("Interpret Subshell: %v", node)
+
+	subshell := i.clone()
+	err := subshell.withRedirects(node.Redirects, func() error {
+		return subshell.interpretNodes(node.Body)
+	})
+	err = subshell.endSubshell(err)
+
+	i.LastStatus = subshell.LastStatus
+	return err
+}
+
+/* Handle the error that ended a copy of the interpreter (like a subshell).
+ * Exiting or returning from a function only ends the copy, so these set the
+ * copy's status instead of being passed on. */
+func (i *Interpreter) endSubshell(err error) error {
+	switch e := err.(type) {
+	case ExitError:
+		if msg := e.Error(); msg != "" && len(i.Files) > 2 && i.Files[2] != nil {
+			fmt.Fprint(i.Files[2], msg)
+		}
+		i.LastStatus = e.ExitCode
+		return nil
+	case ReturnError:
+		i.LastStatus = e.Status
+		return nil
+	}
+	return err
+}
+
+/* Interpret each node in order. The status of the last node is left in
+ * LastStatus. */
+func (i *Interpreter) interpretNodes(nodes []ast.Node) error {
+	for _, node := range nodes {
+		if err := i.Interpret(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		if _, err := proc.ForkExec(); err != nil {
-			return fmt.Errorf("ERROR: failed to run %v: %v\n", proc.Args, err)
+/* Run a while loop, or an until loop if until is true. The condition is
+ * evaluated before each iteration. */
+func (i *Interpreter) interpretLoop(condition []ast.Node, body *ast.DoClause, until bool) error {
+	log.Printf("Interpret Loop: %v %v (until=%v)", condition, body, until)
+
+	i.loopDepth++
+	defer func() { i.loopDepth-- }()
+
+	// the status is zero if the body never runs
+	status := 0
+	for {
+		if err := i.interpretNodes(condition); err != nil {
+			return err
+		}
+		if (i.LastStatus == 0) == until {
+			break
+		}
+
+		err := i.interpretNodes(body.Children)
+		status = i.LastStatus
+		if stop, err := loopControl(err); err != nil {
+			return err
+		} else if stop {
+			break
+		}
+	}
+	i.LastStatus = status
+	return nil
+}
+
+/* Run the body of the loop once for each item in the expanded word list,
+ * assigning the item to the loop variable. Without an "in" clause, the loop
+ * iterates over the positional parameters. */
+func (i *Interpreter) interpretForClause(node *ast.ForClause) error {
+	log.Printf("Interpret ForClause: %v", node)
+
+	items := i.Args
+	if node.In != nil {
+		if words, err := i.expandWords(node.Wordlist); err != nil {
+			return err
+		} else {
+			items = words
+		}
+	}
+
+	i.loopDepth++
+	defer func() { i.loopDepth-- }()
+
+	status := 0
+	for _, item := range items {
+		if err := i.SetVar(node.LoopVar.Text, item); err != nil {
+			return i.exit(err.Error(), 1)
+		}
+
+		err := i.interpretNodes(node.DoClause.Children)
+		status = i.LastStatus
+		if stop, err := loopControl(err); err != nil {
+			return err
+		} else if stop {
+			break
+		}
+	}
+	i.LastStatus = status
+	return nil
+}
+
+/* Handle the error from running one iteration of a loop body, where "break"
+ * and "continue" are returned as errors. Returns true if the loop should
+ * stop, and an error if the error should be passed on to an outer loop (or
+ * is a real error). */
+func loopControl(err error) (bool, error) {
+	switch e := err.(type) {
+	case BreakError:
+		if e.Count > 1 {
+			return true, BreakError{Count: e.Count - 1}
+		}
+		return true, nil
+	case ContinueError:
+		if e.Count > 1 {
+			return true, ContinueError{Count: e.Count - 1}
+		}
+		return false, nil
+	}
+	return err != nil, err
+}
+
+func (i *Interpreter) interpretIfClause(node *ast.IfClause) error {
+	log.Printf("Interpret IfClause: %v", node)
+
+	if err := i.interpretNodes(node.Condition); err != nil {
+		return err
+	}
+
+	if i.LastStatus == 0 {
+		return i.interpretNodes(node.Body)
+	} else if node.Else != nil {
+		return i.interpretNodes(node.Else)
+	}
+
+	// the status is zero when no condition was true and there is no else
+	i.LastStatus = 0
+	return nil
+}
+
+/* Run the body of the first item with a pattern that matches the word. The
+ * item's terminator decides whether to stop there, run the next body (";&"),
+ * or keep matching against the items after it (";;&"). */
+func (i *Interpreter) interpretCaseClause(node *ast.CaseClause) error {
+	log.Printf("Interpret CaseClause: %v", node)
+
+	word, err := i.interpretString(node.Word)
+	if err != nil {
+		return err
+	}
+
+	// the status is zero if no pattern matches
+	status := 0
+	fall_through := false
+	for _, item := range node.Items {
+		if !fall_through {
+			if matched, err := i.matchCaseItem(item, word); err != nil {
+				return err
+			} else if !matched {
+				continue
+			}
+		}
+
+		// an empty body has a status of zero
+		i.LastStatus = 0
+		if err := i.interpretNodes(item.Body); err != nil {
+			return err
+		}
+		status = i.LastStatus
+
+		if item.Terminator == lex.DoubleSemi {
+			break
+		}
+		fall_through = item.Terminator == lex.SemiAnd
+	}
+	i.LastStatus = status
+	return nil
+}
+
+/* Returns true if the word matches any of the item's patterns. The patterns
+ * are expanded one at a time, and only until one matches. */
+func (i *Interpreter) matchCaseItem(item *ast.CaseItem, word string) (bool, error) {
+	for _, pattern := range item.Patterns {
+		if text, err := i.expandPattern(pattern); err != nil {
+			return false, err
+		} else if MatchPattern(text, word) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+/* Run each command of the pipeline concurrently, connecting the stdout of
+ * each command to the stdin of the next command.
+ *
+ * Each command runs in a copy of the interpreter (like a subshell), so the
+ * commands can have different stdin/stdout files. */
+func (i *Interpreter) interpretPipeline(node *ast.Pipeline, is_background bool) error {
+	log.Printf("Interpret Pipeline: %v", node)
+
+	if node.Negated {
+		defer func() {
+			// todo: a negated pipeline must never trigger errexit, if we add it
+			if !is_background {
+				i.LastStatus = negateStatus(i.LastStatus)
+			}
+		}()
+	}
+
+	// a single command (after '!') runs in this interpreter, like any other
+	// command
+	if len(node.Commands) == 1 {
+		return i.interpretCommand(node.Commands[0], is_background)
+	}
+
+	if is_background {
+		subshell := i.clone()
+		i.startSubshellJob(subshell, func() error {
+			return subshell.interpretPipeline(node, false)
+		})
+		return nil
+	}
+
+	stages := make([]*Interpreter, len(node.Commands))
+	for j := range stages {
+		stages[j] = i.clone()
+	}
+
+	// the pipe files that each stage must close when it finishes
+	pipes := make([][]*os.File, len(node.Commands))
+	for j := 0; j < len(stages)-1; j++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			for _, files := range pipes {
+				closeFiles(files)
+			}
+			return err
 		}
+		stages[j].Files[1] = w
+		stages[j+1].Files[0] = r
+		pipes[j] = append(pipes[j], w)
+		pipes[j+1] = append(pipes[j+1], r)
 	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(stages))
+	for j, command := range node.Commands {
+		wg.Add(1)
+		go func(j int, command ast.Command) {
+			defer wg.Done()
+			// close our ends of the pipes, so that neighboring stages see
+			// EOF or EPIPE once this stage is done
+			defer closeFiles(pipes[j])
+			err := stages[j].interpretCommand(command, false)
+			errs[j] = stages[j].endSubshell(err)
+		}(j, command)
+	}
+
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	// the status of a pipeline is the status of the last command
+	i.LastStatus = stages[len(stages)-1].LastStatus
 	return nil
 }
 
-func (i *Interpreter) interpretSimpleCommand(node *ast.SimpleCommand) (*PshProc, error) {
+func negateStatus(status int) int {
+	if status == 0 {
+		return 1
+	}
+	return 0
+}
+
+func closeFiles(files []*os.File) {
+	for _, file := range files {
+		file.Close()
+	}
+}
+
+func (i *Interpreter) interpretSimpleCommand(node *ast.SimpleCommand, is_background bool) error {
 	log.Printf("Interpret SimpleCommand: %v", node)
 
-	args := []string{}
-	for _, word := range node.Words {
-		if text, err := i.interpretString(word); err != nil {
+	i.substituted = false
+	args, err := i.expandWords(node.Words)
+	if err != nil {
+		return err
+	}
+
+	files, opened, err := i.redirectFiles(node.Redirects)
+	// the child has its own copies of the files after the fork, so we can
+	// close the files we opened once the child has started. a builtin or
+	// function run in the background holds them until it finishes.
+	held := newOpenedFiles(opened)
+	defer held.release()
+	if err != nil {
+		// the command is not run if a redirect fails
+		i.printError("%v", err)
+		i.LastStatus = 1
+		return nil
+	}
+
+	// the assignments before a command only apply to that command, and are
+	// in its environment. without a command, like "FOO=bar", they set the
+	// shell's variables.
+	if len(args) > 0 && len(node.Assignments) > 0 {
+		i.Vars.PushTemporaryScope()
+		defer i.Vars.PopScope()
+	}
+	if err := i.assignVariables(node.Assignments, len(args) > 0); err != nil {
+		return err
+	}
+
+	// a command may consist only of assignments and redirects, like "> file"
+	if len(args) == 0 {
+		if !i.substituted {
+			i.LastStatus = 0
+		}
+		return nil
+	}
+
+	// $_ is the last argument of the last simple command
+	i.Vars.Set("_", args[len(args)-1])
+
+	// a command name is looked up as a special builtin, then as a function,
+	// and then as any other builtin
+	builtin, is_builtin := builtins[args[0]]
+	fn, is_function := i.functions[args[0]]
+	if specialBuiltins[args[0]] {
+		is_function = false
+	} else if is_function {
+		is_builtin = false
+	}
+	if is_background && (is_builtin || is_function) {
+		subshell := i.clone()
+		subshell.redirected = append(subshell.redirected, held)
+		i.startSubshellJob(subshell, func() error {
+			if is_builtin {
+				return subshell.runBuiltin(builtin, args, files)
+			}
+			return subshell.callFunction(fn, args, files)
+		})
+		return nil
+	} else if is_builtin {
+		return i.runBuiltin(builtin, args, files)
+	} else if is_function {
+		return i.callFunction(fn, args, files)
+	}
+
+	proc, err := NewPshProc(args, i.Vars.Environ(), i.Dir, files)
+	if err != nil {
+		return err
+	}
+	proc.IsBackground = is_background
+
+	pid, err := proc.ForkExec()
+	if err != nil {
+		// the command could not be run at all
+		if err == syscall.ENOENT && !strings.Contains(args[0], "/") {
+			i.printError("%v: command not found", args[0])
+		} else {
+			i.printError("%v: %v", args[0], err)
+		}
+
+		if err == syscall.ENOENT {
+			i.LastStatus = 127
+		} else {
+			i.LastStatus = 126
+		}
+		return nil
+	}
+	if is_background {
+		i.startProcessJob(pid)
+		return nil
+	}
+	i.LastStatus = proc.ExitStatus()
+	return nil
+}
+
+/* Expand each word to the list of arguments that it produces */
+func (i *Interpreter) expandWords(words []*ast.Str) ([]string, error) {
+	result := []string{}
+	for _, word := range words {
+		if fields, err := i.expandWord(word); err != nil {
 			return nil, err
 		} else {
-			args = append(args, text)
+			result = append(result, fields...)
+		}
+	}
+	return result, nil
+}
+
+/* Expand a word to the fields it produces. The results of unquoted
+ * expansions are split into fields on the characters in IFS. "$@" produces a
+ * field for each positional parameter, and the text before and after it is
+ * joined to the first and last of those fields. Unquoted $@ and $* do the
+ * same, before splitting each parameter. An unquoted expansion that is
+ * replaced by its word, like ${X:-word}, is split piece by piece, so quoted
+ * text in the word is not split. Finally, each field with unquoted pattern
+ * characters is replaced by the pathnames that it matches, if any. */
+func (i *Interpreter) expandWord(word *ast.Str) ([]string, error) {
+	// an empty string, like "", is a single empty field
+	if len(word.Pieces) == 0 {
+		return []string{""}, nil
+	}
+
+	ifs := DEFAULT_IFS
+	if is_set, value := i.FetchVar("IFS"); is_set {
+		ifs = value
+	}
+
+	splitter := newFieldSplitter(ifs)
+	for _, piece := range word.Pieces {
+		if err := i.splitPiece(splitter, piece, false); err != nil {
+			return nil, err
+		}
+	}
+
+	result := []string{}
+	for _, field := range splitter.Fields() {
+		if !field.is_pattern {
+			result = append(result, field.text)
+		} else if paths := Glob(field.pattern, i.Dir); paths != nil {
+			result = append(result, paths...)
+		} else {
+			// a pattern that matches nothing is left as it is
+			result = append(result, field.text)
 		}
 	}
+	return result, nil
+}
+
+/* Expand the piece of a word and add its text to the fields. If in_word is
+ * true, the piece is part of the word of an unquoted expansion, like the "a"
+ * in ${X:-a}, so its text is split even if it is not an expansion. */
+func (i *Interpreter) splitPiece(splitter *fieldSplitter, piece ast.StrPiece, in_word bool) error {
+	split, quoted := isSplit(piece) || (in_word && !isQuoted(piece)), isQuoted(piece)
+	if p, ok := piece.(*ast.ParameterExpansion); ok && expandsToFields(p) {
+		for j, arg := range i.Args {
+			if j > 0 {
+				splitter.endField()
+			}
+			splitter.add(arg, split, quoted)
+		}
+		return nil
+	} else if ok && !p.Quoted && i.usesWord(p) {
+		for _, word_piece := range p.Word.Pieces {
+			if err := i.splitPiece(splitter, word_piece, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	text, err := i.interpretPiece(piece)
+	if err != nil {
+		return err
+	}
+	splitter.add(text, split, quoted)
+	return nil
+}
+
+/* Expand a word to a single pattern, in which quoted pattern characters are
+ * escaped so that they match literally */
+func (i *Interpreter) expandPattern(word *ast.Str) (string, error) {
+	var buffer bytes.Buffer
+	for _, piece := range word.Pieces {
+		text, err := i.interpretPiece(piece)
+		if err != nil {
+			return "", err
+		}
+		for _, c := range text {
+			if isQuoted(piece) && strings.ContainsRune("*?[]\\", c) {
+				buffer.WriteRune('\\')
+			}
+			buffer.WriteRune(c)
+		}
+	}
+	return buffer.String(), nil
+}
+
+/* Returns true if the piece was quoted, so that any pattern characters in its
+ * result match literally */
+func isQuoted(piece ast.StrPiece) bool {
+	switch p := piece.(type) {
+	case ast.QuotedStr:
+		return true
+	case *ast.ParameterExpansion:
+		return p.Quoted
+	case *ast.CommandSubstitution:
+		return p.Quoted
+	case *ast.ArithmeticExpansion:
+		return p.Quoted
+	}
+	return false
+}
+
+/* Returns true if the result of the piece is split into fields, which is
+ * true of unquoted expansions */
+func isSplit(piece ast.StrPiece) bool {
+	switch p := piece.(type) {
+	case *ast.ParameterExpansion:
+		return !p.Quoted
+	case *ast.CommandSubstitution:
+		return !p.Quoted
+	case *ast.ArithmeticExpansion:
+		return !p.Quoted
+	}
+	return false
+}
 
-	if proc, err := NewPshProc(args, i.Env); err != nil {
-		return nil, err
-	} else {
-		return proc, nil
+/* Returns true if the expansion produces a field for each positional
+ * parameter, like "$@" */
+func expandsToFields(p *ast.ParameterExpansion) bool {
+	if p.Operator != nil {
+		return false
 	}
+	return p.VarName.Text == "@" || (p.VarName.Text == "*" && !p.Quoted)
+}
+
+/* Run a builtin with the given files as its stdin, stdout, etc. */
+func (i *Interpreter) runBuiltin(builtin builtinFunc, args []string, files []*os.File) error {
+	log.Printf("Run builtin %v", args)
+
+	saved := i.Files
+	i.Files = files
+	defer func() { i.Files = saved }()
+
+	status, err := builtin(i, args)
+	i.LastStatus = status
+	return err
+}
+
+/* Run a function, with the rest of the arguments as the positional
+ * parameters. The function's body runs in this interpreter, so the function
+ * can change the shell's variables. */
+func (i *Interpreter) callFunction(fn *ast.FunctionDefinition, args []string, files []*os.File) error {
+	log.Printf("Call function %v", args)
+
+	// break and continue do not apply to loops outside the function
+	saved_files, saved_args, saved_depth := i.Files, i.Args, i.loopDepth
+	i.Files, i.Args, i.loopDepth = files, args[1:], 0
+	i.functionDepth++
+	i.Vars.PushScope()
+	defer func() {
+		i.Vars.PopScope()
+		i.functionDepth--
+		i.Files, i.Args, i.loopDepth = saved_files, saved_args, saved_depth
+	}()
+
+	err := i.interpretCompoundCommand(fn.Body)
+	if e, ok := err.(ReturnError); ok {
+		i.LastStatus = e.Status
+		return nil
+	}
+	return err
+}
+
+/* Expand and set each assigned variable, in order, so later values can refer
+ * to earlier ones. If is_temporary, each variable is declared as an exported
+ * variable in the innermost scope, which is the temporary scope of the
+ * command, to be discarded with that scope. */
+func (i *Interpreter) assignVariables(assignments []*ast.Assignment, is_temporary bool) error {
+	for _, assignment := range assignments {
+		value, err := i.interpretString(assignment.Value)
+		if err != nil {
+			return err
+		}
+
+		if is_temporary {
+			if err := i.checkWritable(assignment.Name); err != nil {
+				return i.exit(err.Error(), 1)
+			}
+			i.Vars.DeclareTemporary(assignment.Name).Exported = true
+		}
+		if err := i.SetVar(assignment.Name, value); err != nil {
+			return i.exit(err.Error(), 1)
+		}
+	}
+	return nil
 }
 
 func (i *Interpreter) interpretString(node *ast.Str) (string, error) {
 	var buffer bytes.Buffer
 	for _, piece := range node.Pieces {
-		switch p := piece.(type) {
-		case ast.RawStr:
-			buffer.WriteString(string(p))
-		case *ast.ParameterExpansion:
-			var word_val string
-			var err error
-			var sub string
-
-			if p.Word != nil {
-				if word_val, err = i.interpretString(p.Word); err != nil {
-					return "", err
-				}
-			}
+		if text, err := i.interpretPiece(piece); err != nil {
+			return "", err
+		} else {
+			buffer.WriteString(text)
+		}
+	}
 
-			if sub, err = i.resolveParamExpansion(p, word_val); err != nil {
+	return buffer.String(), nil
+}
+
+func (i *Interpreter) interpretPiece(piece ast.StrPiece) (string, error) {
+	switch p := piece.(type) {
+	case ast.RawStr:
+		return string(p), nil
+	case ast.QuotedStr:
+		return string(p), nil
+	case *ast.ParameterExpansion:
+		var word_val string
+		var err error
+
+		if p.Word != nil {
+			if word_val, err = i.interpretString(p.Word); err != nil {
 				return "", err
-			} else {
-				log.Printf("Evaluated Param Expansion: ${%v} -> %q", p.VarName.Text, sub)
-				buffer.WriteString(sub)
 			}
-		default:
-			return "", fmt.Errorf("Unhandled StringPiece type %v", p)
 		}
+
+		if sub, err := i.resolveParamExpansion(p, word_val); err != nil {
+			return "", err
+		} else {
+			log.Printf("Evaluated Param Expansion: ${%v} -> %q", p.VarName.Text, sub)
+			return sub, nil
+		}
+	case *ast.CommandSubstitution:
+		return i.interpretCommandSubstitution(p)
+	case *ast.ArithmeticExpansion:
+		return i.interpretArithmeticExpansion(p)
+	}
+	return "", fmt.Errorf("Unhandled StringPiece type %v", piece)
+}
+
+/* Run the command in a copy of the interpreter (like a subshell) and return
+ * its output, with trailing newlines removed. The status of the command
+ * becomes $?. */
+func (i *Interpreter) interpretCommandSubstitution(node *ast.CommandSubstitution) (string, error) {
+	log.Printf("Interpret CommandSubstitution: %v", node)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
 	}
+	defer r.Close()
 
-	return buffer.String(), nil
+	// read the output concurrently, so the command does not block on a full pipe
+	var output bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(&output, r)
+		done <- err
+	}()
+
+	subshell := i.clone()
+	subshell.Files[1] = w
+	err = subshell.endSubshell(subshell.Interpret(node.Program))
+	i.LastStatus = subshell.LastStatus
+	i.substituted = true
+
+	// we will see EOF after the command (and any of its children) close the
+	// write end of the pipe
+	w.Close()
+	if read_err := <-done; err == nil {
+		err = read_err
+	}
+	return strings.TrimRight(output.String(), "\n"), err
+}
+
+func (i *Interpreter) interpretArithmeticExpansion(node *ast.ArithmeticExpansion) (string, error) {
+	text, err := i.interpretString(node.Expr)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := i.evalArithmetic(text)
+	if err != nil {
+		return "", fmt.Errorf("psh: %v\n", err)
+	}
+	log.Printf("Evaluated Arithmetic Expansion: $((%v)) -> %v", text, value)
+	return strconv.FormatInt(value, 10), nil
+}
+
+/* Evaluate an arithmetic expression. The expression reads and assigns
+ * variables in the interpreter's environment. */
+func (i *Interpreter) evalArithmetic(text string) (int64, error) {
+	if strings.TrimSpace(text) == "" {
+		return 0, nil
+	}
+
+	expr, err := arith.Parse(text)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %v", strings.TrimSpace(text), err)
+	}
+
+	value, err := arith.Eval(expr, arithVariables{i})
+	if err != nil {
+		return 0, fmt.Errorf("%v: %v", strings.TrimSpace(text), err)
+	}
+	return value, nil
+}
+
+// adapts the interpreter's environment for use in arithmetic expressions
+type arithVariables struct {
+	i *Interpreter
+}
+
+func (v arithVariables) Get(name string) (string, bool) {
+	is_set, value := v.i.FetchVar(name)
+	return value, is_set
+}
+
+func (v arithVariables) Set(name, value string) error {
+	return v.i.SetVar(name, value)
 }
 
 func (i *Interpreter) resolveParamExpansion(p *ast.ParameterExpansion, word_val string) (string, error) {
 	key := p.VarName.Text
-	param_is_set, param_val := i.FetchEnvVar(key)
+	param_is_set, param_val := i.fetchExpandedParam(p)
 	param_is_null := len(param_val) == 0
 
 	if p.Operator == nil {
@@ -198,25 +1055,153 @@ func (i *Interpreter) resolveParamExpansion(p *ast.ParameterExpansion, word_val
 	return "", fmt.Errorf("ERROR: Unhandled param expansion operator %v", p.Operator)
 }
 
-/* Env stores environment variables as a list of "<key>=<value>" strings. This
- * fetches the <value> portion given the <key>, or returns empty string.
+/* Fetch the value of the parameter of an expansion */
+func (i *Interpreter) fetchExpandedParam(p *ast.ParameterExpansion) (bool, string) {
+	if p.VarName.Text == "LINENO" {
+		// the line of the script this expansion is on
+		return true, strconv.Itoa(p.VarName.Line)
+	}
+	return i.fetchParam(p.VarName.Text)
+}
+
+/* Returns true if the expansion is replaced by its word, like ${X:-word}
+ * when X is unset or null */
+func (i *Interpreter) usesWord(p *ast.ParameterExpansion) bool {
+	if p.Operator == nil || p.Word == nil {
+		return false
+	}
+
+	is_set, value := i.fetchExpandedParam(p)
+	switch p.Operator.Type {
+	case lex.ColonDash:
+		return !is_set || value == ""
+	case lex.Dash:
+		return !is_set
+	case lex.Plus:
+		return is_set
+	case lex.ColonPlus:
+		return is_set && value != ""
+	}
+	return false
+}
+
+/* Fetch the value of a parameter. A parameter named by a number, like the 1
+ * in $1, is a positional parameter, except that $0 is the name of the shell
+ * or script. The special parameters are
+ *
+ *	$?	the status of the last command
+ *	$#	the number of positional parameters
+ *	$@	the positional parameters, separated by spaces
+ *	$*	the positional parameters, separated by the first character of IFS
+ *	$$	the process id of the shell. a subshell runs within the shell's
+ *		process, so this is the same in a subshell.
+ *	$!	the process id of the last job started in the background. a job
+ *		like a pipeline or function runs within the shell's process, so
+ *		its process id is the shell's.
+ *	$-	the option flags of the shell
  *
- * This function returns true if the key is set, and false otherwise.
- */
-func (i *Interpreter) FetchEnvVar(key string) (bool, string) {
-	key = key + "="
-	for _, item := range i.Env {
-		if strings.HasPrefix(item, key) {
-			return true, strings.SplitN(item, "=", 2)[1]
+ * Otherwise, the parameter is a variable. */
+func (i *Interpreter) fetchParam(key string) (bool, string) {
+	switch key {
+	case "?":
+		return true, strconv.Itoa(i.LastStatus)
+	case "#":
+		return true, strconv.Itoa(len(i.Args))
+	case "@":
+		return len(i.Args) > 0, strings.Join(i.Args, " ")
+	case "*":
+		// an unset IFS is the same as the default IFS
+		separator := " "
+		if is_set, ifs := i.FetchVar("IFS"); is_set {
+			separator = ""
+			if runes := []rune(ifs); len(runes) > 0 {
+				separator = string(runes[0])
+			}
+		}
+		return len(i.Args) > 0, strings.Join(i.Args, separator)
+	case "$":
+		return true, strconv.Itoa(os.Getpid())
+	case "!":
+		if i.lastJob == 0 {
+			return false, ""
+		}
+		return true, strconv.Itoa(i.lastJob)
+	case "-":
+		return true, i.Flags
+	case "0":
+		return true, i.Name
+	}
+	if n, err := strconv.Atoi(key); err == nil && n > 0 {
+		if n <= len(i.Args) {
+			return true, i.Args[n-1]
 		}
+		return false, ""
+	}
+	return i.FetchVar(key)
+}
+
+/* Fetch the value of a variable. Returns true if the variable is set, and
+ * false otherwise. */
+func (i *Interpreter) FetchVar(name string) (bool, string) {
+	if variable := i.Vars.Get(name); variable != nil && variable.IsSet {
+		return true, variable.Value
 	}
 	return false, ""
 }
 
+/* Set the value of a variable. The value of an integer variable is first
+ * evaluated as an arithmetic expression. */
+func (i *Interpreter) SetVar(name, value string) error {
+	if err := i.checkWritable(name); err != nil {
+		return err
+	}
+	if variable := i.Vars.Get(name); variable != nil && variable.Integer {
+		n, err := i.evalArithmetic(value)
+		if err != nil {
+			return err
+		}
+		value = strconv.FormatInt(n, 10)
+	}
+	i.Vars.Set(name, value)
+	return nil
+}
+
+/* Unset a variable, unless it is readonly */
+func (i *Interpreter) UnsetVar(name string) error {
+	if variable := i.Vars.Get(name); variable != nil && variable.ReadOnly {
+		return fmt.Errorf("%v: cannot unset: readonly variable", name)
+	}
+	i.Vars.Unset(name)
+	return nil
+}
+
+/* Returns an error if the variable is readonly */
+func (i *Interpreter) checkWritable(name string) error {
+	if variable := i.Vars.Get(name); variable != nil && variable.ReadOnly {
+		return fmt.Errorf("%v: readonly variable", name)
+	}
+	return nil
+}
+
+/* Return the path relative to the shell's working directory */
+func (i *Interpreter) resolvePath(name string) string {
+	if filepath.IsAbs(name) || i.Dir == "" {
+		return name
+	}
+	return filepath.Join(i.Dir, name)
+}
+
+/* Print a message, like "psh: <msg>", to the shell's stderr */
+func (i *Interpreter) printError(format string, args ...interface{}) {
+	if len(i.Files) > 2 && i.Files[2] != nil {
+		fmt.Fprintf(i.Files[2], "psh: "+format+"\n", args...)
+	}
+}
+
 func (i *Interpreter) exit(err_msg string, code int) error {
 	// todo: print to stderr?
 	return ExitError{
 		error:    fmt.Errorf("error: %s\n", err_msg),
-		ExitCode: 1,
+		ExitCode: code,
 	}
 }
@@ -0,0 +1,56 @@
+package exe
+
+import (
+	"os"
+	"syscall"
+)
+
+/* Run fn in the background, in the copy of the interpreter given by subshell.
+ * Commands like pipelines and functions run in a goroutine rather than a
+ * process of their own, so like a subshell, the pid of the job is the pid of
+ * the shell. The job holds the files opened by the redirects it runs under,
+ * and releases them when it finishes. */
+func (i *Interpreter) startSubshellJob(subshell *Interpreter, fn func() error) {
+	held := subshell.redirected
+	for _, files := range held {
+		files.hold()
+	}
+
+	i.startJob(os.Getpid(), func() {
+		defer func() {
+			for _, files := range held {
+				files.release()
+			}
+		}()
+		if err := subshell.endSubshell(fn()); err != nil {
+			subshell.printError("%v", err)
+		}
+	})
+}
+
+/* Wait in the background for the process started by a command like "cmd &",
+ * so that it does not linger as a zombie once it exits. */
+func (i *Interpreter) startProcessJob(pid int) {
+	i.startJob(pid, func() {
+		var status syscall.WaitStatus
+		syscall.Wait4(pid, &status, 0, nil)
+	})
+}
+
+/* Run the job in a goroutine, and make pid the value of $!. The shell waits
+ * for every job before it exits. */
+func (i *Interpreter) startJob(pid int, run func()) {
+	i.jobs.Add(1)
+	go func() {
+		defer i.jobs.Done()
+		run()
+	}()
+
+	i.lastJob = pid
+	i.LastStatus = 0
+}
+
+/* Wait for every background job to finish */
+func (i *Interpreter) WaitJobs() {
+	i.jobs.Wait()
+}
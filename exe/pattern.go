@@ -0,0 +1,161 @@
+package exe
+
+import (
+	"strings"
+	"unicode"
+)
+
+// the character classes allowed in bracket expressions, like "[[:digit:]]"
+var CHARACTER_CLASSES = map[string]func(rune) bool{
+	"alnum":  func(c rune) bool { return unicode.IsLetter(c) || unicode.IsDigit(c) },
+	"alpha":  unicode.IsLetter,
+	"blank":  func(c rune) bool { return c == ' ' || c == '\t' },
+	"cntrl":  unicode.IsControl,
+	"digit":  unicode.IsDigit,
+	"graph":  func(c rune) bool { return unicode.IsGraphic(c) && !unicode.IsSpace(c) },
+	"lower":  unicode.IsLower,
+	"print":  unicode.IsPrint,
+	"punct":  unicode.IsPunct,
+	"space":  unicode.IsSpace,
+	"upper":  unicode.IsUpper,
+	"xdigit": func(c rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", c) },
+}
+
+/* Returns true if the text matches the shell pattern. In the pattern,
+ *
+ *	*	matches any string, including the empty string
+ *	?	matches any single character
+ *	[...]	matches any one of the enclosed characters (a bracket expression)
+ *	\c	matches the character c
+ *
+ * A bracket expression may contain ranges, like "a-z", and character classes,
+ * like "[:alpha:]". It is negated if it starts with '!' (or '^'). A '[' that
+ * does not begin a valid bracket expression matches itself.
+ */
+func MatchPattern(pattern, text string) bool {
+	p, t := []rune(pattern), []rune(text)
+
+	// on a mismatch, we backtrack to the last '*' and let it match one more
+	// character. there is no need to remember more than the last '*'.
+	star_p, star_t := -1, -1
+	pi, ti := 0, 0
+	for ti < len(t) {
+		if pi < len(p) {
+			switch p[pi] {
+			case '*':
+				star_p, star_t = pi, ti
+				pi++
+				continue
+			case '?':
+				pi++
+				ti++
+				continue
+			case '[':
+				if matched, end, ok := matchBracket(p, pi, t[ti]); ok {
+					if matched {
+						pi = end
+						ti++
+						continue
+					}
+				} else if t[ti] == '[' {
+					pi++
+					ti++
+					continue
+				}
+			case '\\':
+				if pi+1 < len(p) && p[pi+1] == t[ti] {
+					pi += 2
+					ti++
+					continue
+				} else if pi+1 == len(p) && t[ti] == '\\' {
+					pi++
+					ti++
+					continue
+				}
+			default:
+				if p[pi] == t[ti] {
+					pi++
+					ti++
+					continue
+				}
+			}
+		}
+
+		if star_p < 0 {
+			return false
+		}
+		star_t++
+		pi, ti = star_p+1, star_t
+	}
+
+	// the text is consumed. the rest of the pattern must match nothing.
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+/* Match a character against the bracket expression starting at p[start].
+ * Returns whether the character matched, the index in the pattern just after
+ * the bracket expression, and false if there is no valid bracket expression. */
+func matchBracket(p []rune, start int, c rune) (bool, int, bool) {
+	i := start + 1
+	negate := false
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		negate = true
+		i++
+	}
+
+	matched := false
+	for first := true; i < len(p); first = false {
+		// a ']' ends the expression, unless it is the first character
+		if p[i] == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+
+		// a character class, like "[:alpha:]"
+		if p[i] == '[' && i+1 < len(p) && p[i+1] == ':' {
+			if end := indexClassEnd(p[i+2:]); end >= 0 {
+				name := string(p[i+2 : i+2+end])
+				if class, ok := CHARACTER_CLASSES[name]; ok && class(c) {
+					matched = true
+				}
+				i += end + 4
+				continue
+			}
+		}
+
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+		i++
+
+		// a range, like "a-z". a '-' at the end matches itself.
+		hi := lo
+		if i+1 < len(p) && p[i] == '-' && p[i+1] != ']' {
+			hi = p[i+1]
+			if hi == '\\' && i+2 < len(p) {
+				hi = p[i+2]
+				i++
+			}
+			i += 2
+		}
+
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+	return false, 0, false
+}
+
+/* Returns the index of the first ":]" in the runes, or -1 */
+func indexClassEnd(runes []rune) int {
+	for i := 0; i+1 < len(runes); i++ {
+		if runes[i] == ':' && runes[i+1] == ']' {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,195 @@
+package exe
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/pglass/pshhh/ast"
+	"github.com/pglass/pshhh/lex"
+)
+
+/* Run fn with the redirects applied to the interpreter's files. This is used
+ * for compound commands, where the redirects apply to every command inside
+ * the compound command. */
+func (i *Interpreter) withRedirects(redirects []*ast.IoRedirect, fn func() error) error {
+	if len(redirects) == 0 {
+		return fn()
+	}
+
+	files, opened, err := i.redirectFiles(redirects)
+	if err != nil {
+		closeFiles(opened)
+		i.printError("%v", err)
+		i.LastStatus = 1
+		return nil
+	}
+
+	// a job started in the background by fn may still be using the files
+	// after fn returns, so the files are closed once it is done with them
+	held := newOpenedFiles(opened)
+	defer held.release()
+
+	saved_files, saved_redirected := i.Files, i.redirected
+	i.Files, i.redirected = files, append(i.redirected, held)
+	defer func() { i.Files, i.redirected = saved_files, saved_redirected }()
+	return fn()
+}
+
+/* Files opened by redirects, which are closed when the last command using
+ * them releases them. A background job holds the files until it finishes. */
+type openedFiles struct {
+	files []*os.File
+	refs  int32
+}
+
+/* Return the files, held once by the caller */
+func newOpenedFiles(files []*os.File) *openedFiles {
+	return &openedFiles{files: files, refs: 1}
+}
+
+func (f *openedFiles) hold() {
+	atomic.AddInt32(&f.refs, 1)
+}
+
+func (f *openedFiles) release() {
+	if atomic.AddInt32(&f.refs, -1) == 0 {
+		closeFiles(f.files)
+	}
+}
+
+/* Apply redirects to a copy of the interpreter's files. This returns the
+ * redirected files, indexed by file descriptor, along with the files opened
+ * by the redirects. The caller must close the opened files when it is done
+ * with them (even if there is an error).
+ *
+ * Redirects are applied in order, so "> out 2>&1" sends both stdout and
+ * stderr to "out", while "2>&1 > out" sends stderr to the original stdout.
+ */
+func (i *Interpreter) redirectFiles(redirects []*ast.IoRedirect) ([]*os.File, []*os.File, error) {
+	files := make([]*os.File, len(i.Files))
+	copy(files, i.Files)
+
+	opened := []*os.File{}
+	for _, redirect := range redirects {
+		fd, file, err := i.openRedirect(redirect, files)
+		if err != nil {
+			return nil, opened, err
+		}
+		if file != nil && !isDuplicate(redirect) {
+			opened = append(opened, file)
+		}
+
+		for len(files) <= fd {
+			files = append(files, nil)
+		}
+		files[fd] = file
+	}
+	return files, opened, nil
+}
+
+/* Return the file descriptor a redirect applies to, and the file that
+ * descriptor should refer to. A nil file means the descriptor is closed. */
+func (i *Interpreter) openRedirect(redirect *ast.IoRedirect, files []*os.File) (int, *os.File, error) {
+	fd := defaultRedirectFd(redirect.IoOperator.Type)
+	if redirect.IoNumber != nil {
+		n, err := strconv.Atoi(redirect.IoNumber.Text)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%v: bad file descriptor", redirect.IoNumber.Text)
+		}
+		fd = n
+	}
+
+	if redirect.HereDoc != nil {
+		file, err := i.openHereDoc(redirect.HereDoc)
+		return fd, file, err
+	}
+
+	target, err := i.interpretString(redirect.FilenameOrHereEnd)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var flags int
+	switch redirect.IoOperator.Type {
+	case lex.Less:
+		flags = os.O_RDONLY
+	case lex.Great, lex.Clobber:
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	case lex.DoubleGreat:
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	case lex.LessGreat:
+		flags = os.O_RDWR | os.O_CREATE
+	case lex.LessAnd, lex.GreatAnd:
+		file, err := duplicateFd(target, files)
+		return fd, file, err
+	default:
+		return 0, nil, fmt.Errorf("Unhandled redirect operator %v", redirect.IoOperator.Text)
+	}
+
+	file, err := os.OpenFile(i.resolvePath(target), flags, 0666)
+	if err != nil {
+		if e, ok := err.(*os.PathError); ok {
+			err = e.Err
+		}
+		return 0, nil, fmt.Errorf("%v: %v", target, err)
+	}
+	return fd, file, nil
+}
+
+/* Return a file from which the body of the here-document can be read */
+func (i *Interpreter) openHereDoc(here_doc *ast.Str) (*os.File, error) {
+	body, err := i.interpretString(here_doc)
+	if err != nil {
+		return nil, err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	// write from a goroutine so that a large body does not block on a full
+	// pipe. if the command exits without reading the body, the write fails
+	// once the read end is closed.
+	go func() {
+		defer w.Close()
+		io.WriteString(w, body)
+	}()
+	return r, nil
+}
+
+/* Handle the target of "<&" or ">&". The target is either a file descriptor
+ * to duplicate, or "-" to close the file descriptor. */
+func duplicateFd(target string, files []*os.File) (*os.File, error) {
+	if target == "-" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(target)
+	if err != nil {
+		return nil, fmt.Errorf("%v: ambiguous redirect", target)
+	}
+	if n < 0 || n >= len(files) || files[n] == nil {
+		return nil, fmt.Errorf("%v: bad file descriptor", target)
+	}
+	return files[n], nil
+}
+
+func isDuplicate(redirect *ast.IoRedirect) bool {
+	switch redirect.IoOperator.Type {
+	case lex.LessAnd, lex.GreatAnd:
+		return true
+	}
+	return false
+}
+
+func defaultRedirectFd(ttype lex.TokenType) int {
+	switch ttype {
+	case lex.Less, lex.LessAnd, lex.LessGreat, lex.DoubleLess, lex.DoubleLessDash:
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,202 @@
+package exe
+
+import (
+	"sort"
+	"strings"
+)
+
+/* A shell variable and its attributes. A variable may exist without being
+ * set, like after "export FOO" or "local FOO", so that it keeps its
+ * attributes until it is given a value. */
+type Variable struct {
+	Value string
+	IsSet bool
+
+	// exported variables are in the environment of the commands we run
+	Exported bool
+	// readonly variables cannot be assigned or unset
+	ReadOnly bool
+	// the value of an integer variable is evaluated as an arithmetic
+	// expression when it is assigned
+	Integer bool
+}
+
+/* The shell's variables. Each function call pushes a scope for the
+ * variables made local to the call. A variable is looked up in the innermost
+ * scope that has it, and then in the global variables, so a function sees
+ * the local variables of the functions that called it. */
+type Variables struct {
+	globals map[string]*Variable
+	scopes  []*scope
+}
+
+/* The variables of one scope. A temporary scope holds the assignments before
+ * a command, like the "X=1" in "X=1 cmd". Variables are never declared in a
+ * temporary scope, so "X=1 local y" makes y local to the function. */
+type scope struct {
+	vars      map[string]*Variable
+	temporary bool
+}
+
+func NewVariables() *Variables {
+	return &Variables{
+		globals: map[string]*Variable{},
+		scopes:  []*scope{},
+	}
+}
+
+/* Set each "<key>=<value>" item as an exported variable */
+func (v *Variables) Import(env []string) {
+	for _, item := range env {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		v.Set(parts[0], parts[1])
+		v.Ensure(parts[0]).Exported = true
+	}
+}
+
+/* Return a deep copy of the variables, for a subshell */
+func (v *Variables) Copy() *Variables {
+	result := &Variables{
+		globals: copyScope(v.globals),
+		scopes:  make([]*scope, len(v.scopes)),
+	}
+	for j, s := range v.scopes {
+		result.scopes[j] = &scope{vars: copyScope(s.vars), temporary: s.temporary}
+	}
+	return result
+}
+
+func copyScope(scope map[string]*Variable) map[string]*Variable {
+	result := map[string]*Variable{}
+	for name, variable := range scope {
+		copied := *variable
+		result[name] = &copied
+	}
+	return result
+}
+
+/* Start a new scope, like when calling a function */
+func (v *Variables) PushScope() {
+	v.scopes = append(v.scopes, &scope{vars: map[string]*Variable{}})
+}
+
+/* Start a new temporary scope, for the assignments before a command */
+func (v *Variables) PushTemporaryScope() {
+	v.scopes = append(v.scopes, &scope{vars: map[string]*Variable{}, temporary: true})
+}
+
+/* Discard the innermost scope and its variables */
+func (v *Variables) PopScope() {
+	v.scopes = v.scopes[:len(v.scopes)-1]
+}
+
+/* Return the scope that has the variable, or nil, and whether the scope is
+ * a local scope */
+func (v *Variables) scopeOf(name string) (map[string]*Variable, bool) {
+	for j := len(v.scopes) - 1; j >= 0; j-- {
+		if _, ok := v.scopes[j].vars[name]; ok {
+			return v.scopes[j].vars, true
+		}
+	}
+	if _, ok := v.globals[name]; ok {
+		return v.globals, false
+	}
+	return nil, false
+}
+
+/* Return the variable, or nil if there is no such variable */
+func (v *Variables) Get(name string) *Variable {
+	if scope, _ := v.scopeOf(name); scope != nil {
+		return scope[name]
+	}
+	return nil
+}
+
+/* Return the variable, first creating an unset global variable if there is
+ * no such variable. This is how attributes are given to a variable. */
+func (v *Variables) Ensure(name string) *Variable {
+	if variable := v.Get(name); variable != nil {
+		return variable
+	}
+	variable := &Variable{}
+	v.globals[name] = variable
+	return variable
+}
+
+/* Create an unset variable in the innermost scope that is not temporary,
+ * hiding any variable of the same name in the outer scopes. */
+func (v *Variables) Declare(name string) *Variable {
+	vars := v.globals
+	for j := len(v.scopes) - 1; j >= 0; j-- {
+		if !v.scopes[j].temporary {
+			vars = v.scopes[j].vars
+			break
+		}
+	}
+	variable := &Variable{}
+	vars[name] = variable
+	return variable
+}
+
+/* Create an unset variable in the innermost scope, even if it is temporary */
+func (v *Variables) DeclareTemporary(name string) *Variable {
+	if len(v.scopes) == 0 {
+		return v.Declare(name)
+	}
+	variable := &Variable{}
+	v.scopes[len(v.scopes)-1].vars[name] = variable
+	return variable
+}
+
+/* Set the value of the variable, creating a global variable if there is no
+ * such variable. This does not check the variable's attributes. */
+func (v *Variables) Set(name, value string) {
+	variable := v.Ensure(name)
+	variable.Value = value
+	variable.IsSet = true
+}
+
+/* Unset the variable. A local variable stays local to its scope, so that
+ * unsetting it does not reveal the variable it hides. */
+func (v *Variables) Unset(name string) {
+	if scope, is_local := v.scopeOf(name); is_local {
+		scope[name] = &Variable{}
+	} else if scope != nil {
+		delete(scope, name)
+	}
+}
+
+/* Return the names of the visible variables, in sorted order */
+func (v *Variables) Names() []string {
+	seen := map[string]bool{}
+	for name := range v.globals {
+		seen[name] = true
+	}
+	for _, s := range v.scopes {
+		for name := range s.vars {
+			seen[name] = true
+		}
+	}
+
+	names := []string{}
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/* Return the exported variables that are set, as a list of "<key>=<value>"
+ * strings, for the environment of a command */
+func (v *Variables) Environ() []string {
+	env := []string{}
+	for _, name := range v.Names() {
+		if variable := v.Get(name); variable.Exported && variable.IsSet {
+			env = append(env, name+"="+variable.Value)
+		}
+	}
+	return env
+}
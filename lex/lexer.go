@@ -17,18 +17,42 @@ type Lexer struct {
 	tokens chan Token
 	state  stateFn
 
+	// the position just after the last here-document body that was read on
+	// the current line, or zero. the lexer skips over the here-document
+	// bodies when it reaches the end of the line.
+	hereDocEnd int
+
 	peekBuf []Token
 }
 
 func NewLexer(input string) *Lexer {
-	lexer := &Lexer{
+	return NewLexerAt(input, 1)
+}
+
+/* Create a lexer for input that starts on the given line of a script, like
+ * the command in `cmd`, so that the tokens have the lines of the script. */
+func NewLexerAt(input string, line int) *Lexer {
+	lexer := newLexer(input, line)
+	go lexer.run(lexText)
+	return lexer
+}
+
+/* Create a lexer for the body of a here-document, which starts on the given
+ * line. The body is lexed like the contents of a double-quoted string, except
+ * that double quotes are not special. */
+func NewHereDocLexer(input string, line int) *Lexer {
+	lexer := newLexer(input, line)
+	go lexer.run(lexHereDocContents)
+	return lexer
+}
+
+func newLexer(input string, line int) *Lexer {
+	return &Lexer{
 		input:   input,
-		line:    1,
+		line:    line,
 		tokens:  make(chan Token),
 		peekBuf: []Token{},
 	}
-	go lexer.run()
-	return lexer
 }
 
 func (lx *Lexer) nextRune() rune {
@@ -69,6 +93,31 @@ func (lx *Lexer) Peek() Token {
 	return lx.peekBuf[0]
 }
 
+/* Peek at the next token as a reserved word, like "do", if it is a Name that
+ * is one. A reserved word is only recognized where the grammar allows it,
+ * like at the start of a command, so the parser calls this in those places.
+ * Anywhere else, like in "echo done", it is an ordinary word. If any types
+ * are given, only those reserved words are recognized, like the "esac" that
+ * may come where a case pattern is expected. */
+func (lx *Lexer) PeekReserved(ttypes ...TokenType) Token {
+	tok := lx.Peek()
+	ttype, ok := RESERVED_WORDS[tok.Text]
+	if !ok || tok.Type != Name {
+		return tok
+	}
+	for _, allowed := range ttypes {
+		ok = ttype == allowed
+		if ok {
+			break
+		}
+	}
+	if ok {
+		tok.Type = ttype
+		lx.peekBuf[0] = tok
+	}
+	return tok
+}
+
 func (lx *Lexer) Unread(tok Token) {
 	lx.peekBuf = append([]Token{tok}, lx.peekBuf...)
 }
@@ -97,13 +146,6 @@ func (lx *Lexer) emitText(ttype TokenType, text string) {
 	}
 
 	token.Line -= strings.Count(token.Text, "\n")
-
-	switch token.Type {
-	case Name:
-		if ttype, ok := RESERVED_WORDS[token.Text]; ok {
-			token.Type = ttype
-		}
-	}
 	lx.tokens <- token
 	lx.start = lx.pos
 
@@ -130,9 +172,9 @@ func (lx *Lexer) errorf(format string, args ...interface{}) stateFn {
 
 // this is run in a coroutine. it calls each state function in succession.
 // the state functions use the lexer to emit tokens, by calling lx.emit().
-func (lx *Lexer) run() {
-	for lx.state = lexText; lx.state != nil; {
-		lx.state = lx.state(lx, lexText)
+func (lx *Lexer) run(start stateFn) {
+	for lx.state = start; lx.state != nil; {
+		lx.state = lx.state(lx, start)
 	}
 	close(lx.tokens)
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type stateFn func(*Lexer, stateFn) stateFn
@@ -25,8 +26,13 @@ func lexText(lx *Lexer, nextState stateFn) stateFn {
 	if c == eof {
 		lx.emit(EOF)
 		return nil
-	} else if c == '$' {
+	} else if c == '$' && lx.hasDollarExpansion() {
 		return lexDollarExpansion(lx, nextState)
+	} else if c == '$' {
+		// a '$' that does not start an expansion is an ordinary character
+		lx.nextRune()
+		lx.emit(Word)
+		return nextState
 	} else if IsWordChar(c) {
 		return lexWord(lx, nextState)
 	} else if unicode.IsSpace(c) {
@@ -35,43 +41,101 @@ func lexText(lx *Lexer, nextState stateFn) stateFn {
 		return lexSingleQuotedString(lx, nextState)
 	} else if c == '"' {
 		return lexDoubleQuotedString(lx, nextState)
-	} else if unicode.IsPunct(c) || unicode.IsSymbol(c) {
+	} else if c == '`' {
+		return lexBackquotedCommand(lx, nextState)
+	} else if c == '\\' {
+		return lexEscapedChar(lx, nextState)
+	} else if strings.ContainsRune(OPERATOR_CHARS, c) {
 		return lexOperator(lx, nextState)
 	} else {
 		return lx.errorf("Unexpected rune %q", c)
 	}
 }
 
-// Read a Word token (which may actually be a Name or Number)
+// Read a Word token (which may actually be a Name, Number or AssignmentWord)
 func lexWord(lx *Lexer, nextState stateFn) stateFn {
+	for c := lx.peekRune(); IsWordChar(c); c = lx.peekRune() {
+		lx.nextRune()
+		if c == '[' {
+			lx.acceptBracketExpression()
+		}
+	}
+	lx.emitWord()
+	return nextState
+}
+
+/* Emit the word that was read as a Word, Name, Number or AssignmentWord */
+func (lx *Lexer) emitWord() {
+	text := lx.input[lx.start:lx.pos]
+
 	// A WORD consisting of only digits is a NUMBER
 	// A WORD that does not start with a digit is a NAME
-	if unicode.IsDigit(lx.peekRune()) {
-		return lexNumberOrWord(lx, nextState)
+	if strings.TrimLeft(text, "0123456789") == "" {
+		lx.emit(Number)
+	} else if unicode.IsDigit(rune(text[0])) {
+		lx.emit(Word)
+	} else if index := strings.IndexRune(text, '='); index > 0 && IsName(text[:index]) {
+		// A WORD like NAME=value is an ASSIGNMENT_WORD. The value may
+		// continue in the tokens after this one, like the '"$X"' in FOO="$X".
+		lx.emit(AssignmentWord)
 	} else {
-		for IsWordChar(lx.peekRune()) {
-			lx.nextRune()
-		}
+		lx.emit(Name)
 	}
+}
 
-	lx.emit(Name)
+/* Lex a backslash outside of quotes, which quotes the character after it. A
+ * backslash before a newline is a line continuation, and both are removed.
+ *
+ *	echo a\ b\
+ *	c
+ *
+ *	Name		"echo"
+ *	Space		" "
+ *	Name		"a"
+ *	EscapedChar	" "
+ *	Name		"b"
+ *	Name		"c"
+ */
+func lexEscapedChar(lx *Lexer, nextState stateFn) stateFn {
+	if c := lx.nextRune(); c != '\\' {
+		return lx.errorf("Expected '\\' to start an escaped character (got %c)", c)
+	}
+
+	c := lx.nextRune()
+	if c == eof {
+		// a trailing backslash is just a backslash
+		lx.emitText(EscapedChar, "\\")
+	} else if c == '\n' {
+		lx.start = lx.pos
+	} else {
+		lx.emitText(EscapedChar, string(c))
+	}
 	return nextState
 }
 
-func lexNumberOrWord(lx *Lexer, nextState stateFn) stateFn {
-	if c := lx.nextRune(); !unicode.IsDigit(c) {
-		return lx.errorf("Expected Name or Number to start with a digit (got %c)", c)
+/* Consume the rest of a bracket expression in a pattern, like the "!a-z]" in
+ * "[!a-z]", which may contain characters that are not word characters. A ']'
+ * right after the '[' (or after the '!' or '^') is part of the expression.
+ * Nothing is consumed if there is no closing ']' before a blank, an operator
+ * character, a quote, or an expansion, since those end the word even inside
+ * the brackets. */
+func (lx *Lexer) acceptBracketExpression() {
+	text := lx.input[lx.pos:]
+	end := 0
+	if strings.HasPrefix(text, "!") || strings.HasPrefix(text, "^") {
+		end++
 	}
-
-	ttype := Number
-	for IsWordChar(lx.peekRune()) {
-		c := lx.nextRune()
-		if !unicode.IsDigit(c) {
-			ttype = Word
+	if strings.HasPrefix(text[end:], "]") {
+		end++
+	}
+	for ; end < len(text); end++ {
+		if text[end] == ']' {
+			lx.pos += end + 1
+			return
+		} else if unicode.IsSpace(rune(text[end])) || strings.ContainsRune(OPERATOR_CHARS+"$`'\"\\", rune(text[end])) {
+			return
 		}
 	}
-	lx.emit(ttype)
-	return nextState
 }
 
 func lexSpace(lx *Lexer, nextState stateFn) stateFn {
@@ -79,6 +143,7 @@ func lexSpace(lx *Lexer, nextState stateFn) stateFn {
 		return lx.errorf("Expected Space or Newline to start with a space char (got %c)", c)
 	} else if c == '\n' {
 		lx.emit(Newline)
+		lx.skipHereDocs()
 	} else {
 		for {
 			c := lx.peekRune()
@@ -117,9 +182,132 @@ func lexOperator(lx *Lexer, nextState stateFn) stateFn {
 		lx.nextRune()
 	}
 	lx.emit(result_ttype)
+
+	switch result_ttype {
+	case DoubleLess:
+		return lexHereDoc(lx, nextState, false)
+	case DoubleLessDash:
+		return lexHereDoc(lx, nextState, true)
+	}
+	return nextState
+}
+
+/* Lex the delimiter of a here-document, and then read the body of the
+ * here-document. The body begins on the line after the delimiter, so the
+ * body is emitted immediately after the delimiter as a HereDoc token:
+ *
+ *	cat <<EOF | sort
+ *	b
+ *	a
+ *	EOF
+ *
+ *	Name		"cat"
+ *	Space		" "
+ *	DoubleLess	"<<"
+ *	Word		"EOF"
+ *	HereDoc		"b\na\n"
+ *	Space		" "
+ *	Pipe		"|"
+ *	...
+ *
+ * The delimiter keeps any quotes, so that the parser can tell whether the
+ * body should be expanded. When strip_tabs is true (for "<<-"), leading tabs
+ * are removed from each line of the body and from the delimiter line.
+ */
+func lexHereDoc(lx *Lexer, nextState stateFn, strip_tabs bool) stateFn {
+	for c := lx.peekRune(); c != '\n' && unicode.IsSpace(c); c = lx.peekRune() {
+		lx.nextRune()
+	}
+	if lx.pos > lx.start {
+		lx.emit(Space)
+	}
+
+	// read the delimiter, removing quotes
+	var delim bytes.Buffer
+	for {
+		c := lx.peekRune()
+		if c == eof || unicode.IsSpace(c) || strings.ContainsRune(";&|<>()", c) {
+			break
+		}
+		lx.nextRune()
+
+		switch c {
+		case '\'':
+			for c = lx.nextRune(); c != '\'' && c != eof; c = lx.nextRune() {
+				delim.WriteRune(c)
+			}
+		case '"':
+			for c = lx.nextRune(); c != '"' && c != eof; c = lx.nextRune() {
+				if c == '\\' && strings.ContainsRune("$`\"\\", lx.peekRune()) {
+					c = lx.nextRune()
+				}
+				delim.WriteRune(c)
+			}
+		case '\\':
+			if cc := lx.nextRune(); cc != eof {
+				delim.WriteRune(cc)
+			}
+		default:
+			delim.WriteRune(c)
+		}
+	}
+
+	// there is no delimiter. let the parser complain about it.
+	if lx.pos == lx.start {
+		return nextState
+	}
+	lx.emit(Word)
+
+	// the body starts after the current line, or after the previous body if
+	// there are several here-documents on this line
+	start := lx.hereDocEnd
+	if start == 0 {
+		if index := strings.IndexRune(lx.input[lx.pos:], '\n'); index >= 0 {
+			start = lx.pos + index + 1
+		} else {
+			start = len(lx.input)
+		}
+	}
+
+	var body bytes.Buffer
+	end := start
+	for end < len(lx.input) {
+		line := lx.input[end:]
+		if index := strings.IndexRune(line, '\n'); index >= 0 {
+			line = line[:index+1]
+		}
+		end += len(line)
+
+		text := line
+		if strip_tabs {
+			text = strings.TrimLeft(text, "\t")
+		}
+		if strings.TrimSuffix(text, "\n") == delim.String() {
+			break
+		}
+		body.WriteString(text)
+	}
+
+	lx.tokens <- Token{
+		Type: HereDoc,
+		Text: body.String(),
+		Pos:  start,
+		Line: lx.line + strings.Count(lx.input[lx.pos:start], "\n"),
+	}
+	lx.hereDocEnd = end
 	return nextState
 }
 
+/* Skip past the bodies of any here-documents on the line just lexed */
+func (lx *Lexer) skipHereDocs() {
+	if lx.hereDocEnd > lx.pos {
+		lx.line += strings.Count(lx.input[lx.pos:lx.hereDocEnd], "\n")
+		lx.pos = lx.hereDocEnd
+		lx.start = lx.pos
+	}
+	lx.hereDocEnd = 0
+}
+
 func lexSingleQuotedString(lx *Lexer, nextState stateFn) stateFn {
 	if c := lx.nextRune(); c != '\'' {
 		return lx.errorf("Expected single quote to start a string (got %c)", c)
@@ -176,9 +364,12 @@ func lexDoubleQuotedStringContents(lx *Lexer, nextState stateFn) stateFn {
 			} else {
 				buffer.WriteRune(c)
 			}
-		} else if c == '$' {
+		} else if c == '$' && lx.hasDollarExpansion() {
 			lx.emitBuffer(StringSegment, buffer)
 			return composeStates(lx, lexDollarExpansion, lexDoubleQuotedStringContents, nextState)
+		} else if c == '`' {
+			lx.emitBuffer(StringSegment, buffer)
+			return composeStates(lx, lexBackquotedCommand, lexDoubleQuotedStringContents, nextState)
 		} else {
 			lx.nextRune()
 			buffer.WriteRune(c)
@@ -187,6 +378,52 @@ func lexDoubleQuotedStringContents(lx *Lexer, nextState stateFn) stateFn {
 	return nextState
 }
 
+/* Lex the body of a here-document. This is like the contents of a double
+ * quoted string, except double quotes are not special and a backslash only
+ * escapes '$', '`', '\' and newline. */
+func lexHereDocContents(lx *Lexer, nextState stateFn) stateFn {
+	var buffer bytes.Buffer
+	for {
+		c := lx.peekRune()
+		if c == eof {
+			lx.emitBuffer(StringSegment, buffer)
+			lx.emit(EOF)
+			return nil
+		} else if c == '\\' {
+			lx.nextRune()
+			cc := lx.peekRune()
+			if strings.ContainsRune("$`\\", cc) {
+				lx.nextRune()
+				buffer.WriteRune(cc)
+			} else if cc == '\n' {
+				lx.nextRune()
+			} else {
+				buffer.WriteRune(c)
+			}
+		} else if c == '$' && lx.hasDollarExpansion() {
+			lx.emitBuffer(StringSegment, buffer)
+			return composeStates(lx, lexDollarExpansion, lexHereDocContents, nextState)
+		} else if c == '`' {
+			lx.emitBuffer(StringSegment, buffer)
+			return composeStates(lx, lexBackquotedCommand, lexHereDocContents, nextState)
+		} else {
+			lx.nextRune()
+			buffer.WriteRune(c)
+		}
+	}
+}
+
+/* Returns true if the next '$' starts an expansion, which it does when it is
+ * followed by a name, a digit, a special parameter, '{' or '('. Otherwise,
+ * like in "echo $" or "a$ b", the '$' is an ordinary character. */
+func (lx *Lexer) hasDollarExpansion() bool {
+	if !lx.hasString("$") {
+		return false
+	}
+	c, _ := utf8.DecodeRuneInString(lx.input[lx.pos+1:])
+	return c == '{' || c == '(' || IsNameChar(c) || IsSpecialParameter(c)
+}
+
 func lexDollarExpansion(lx *Lexer, nextState stateFn) stateFn {
 	if c := lx.nextRune(); c != '$' {
 		return lx.errorf("Expected '$' to start dollar expansion (got %c)", c)
@@ -197,10 +434,20 @@ func lexDollarExpansion(lx *Lexer, nextState stateFn) stateFn {
 	c := lx.peekRune()
 	if c == '{' {
 		return lexBraceExpansion(lx, nextState)
+	} else if lx.hasString("((") {
+		return lexArithmeticExpansion(lx, nextState)
 	} else if c == '(' {
 		return lexParenExpansion(lx, nextState)
+	} else if unicode.IsDigit(c) {
+		// without braces, a positional parameter is a single digit, so $10
+		// is $1 followed by a 0
+		lx.nextRune()
+		lx.emit(Number)
+		return nextState
 	} else if IsNameChar(c) {
 		return lexName(lx, nextState)
+	} else if IsSpecialParameter(c) {
+		return lexSpecialParameter(lx, nextState)
 	}
 	return nextState
 }
@@ -213,8 +460,12 @@ func lexBraceExpansion(lx *Lexer, nextState stateFn) stateFn {
 	}
 
 	c := lx.peekRune()
-	if IsNameChar(c) {
+	if unicode.IsDigit(c) {
+		return composeStates(lx, lexPositionalParameter, lexBraceExpansionEnd, nextState)
+	} else if IsNameChar(c) {
 		return composeStates(lx, lexName, lexBraceExpansionEnd, nextState)
+	} else if IsSpecialParameter(c) {
+		return composeStates(lx, lexSpecialParameter, lexBraceExpansionEnd, nextState)
 	}
 
 	return nextState
@@ -231,19 +482,208 @@ func lexName(lx *Lexer, nextState stateFn) stateFn {
 	return nextState
 }
 
+// Lex the number of a positional parameter in braces, like the 10 in ${10}
+func lexPositionalParameter(lx *Lexer, nextState stateFn) stateFn {
+	for c := lx.peekRune(); unicode.IsDigit(c); c = lx.peekRune() {
+		lx.nextRune()
+	}
+	lx.emit(Number)
+	return nextState
+}
+
+/* Lex the single character naming a special parameter, like the "?" in $? */
+func lexSpecialParameter(lx *Lexer, nextState stateFn) stateFn {
+	if c := lx.nextRune(); !IsSpecialParameter(c) {
+		return lx.errorf("Expected a special parameter (got %c)", c)
+	}
+	lx.emit(SpecialParameter)
+	return nextState
+}
+
 func lexBraceExpansionEnd(lx *Lexer, nextState stateFn) stateFn {
 	c := lx.peekRune()
 	if strings.ContainsRune(":+-=?", c) {
-		return composeStates(lx, lexOperator, lexText, lexOperator, nextState)
-	} else if c == '}' {
+		return composeStates(lx, lexOperator, lexBraceExpansionWord, lexBraceExpansionClose, nextState)
+	}
+	return lexBraceExpansionClose(lx, nextState)
+}
+
+/* Lex the word after the operator in a brace expansion, like the "b" in
+ * ${a:-b}. The word ends at the closing '}'. */
+func lexBraceExpansionWord(lx *Lexer, nextState stateFn) stateFn {
+	c := lx.peekRune()
+	if c == '}' {
+		return nextState
+	} else if !IsWordChar(c) {
+		return lexText(lx, nextState)
+	}
+	for c := lx.peekRune(); IsWordChar(c) && c != '}'; c = lx.peekRune() {
 		lx.nextRune()
-		lx.emit(RightBrace)
-	} else {
+	}
+	lx.emitWord()
+	return nextState
+}
+
+func lexBraceExpansionClose(lx *Lexer, nextState stateFn) stateFn {
+	if c := lx.nextRune(); c != '}' {
 		return lx.errorf("Unclosed brace expansion (expected '}')")
 	}
+	lx.emit(RightBrace)
 	return nextState
 }
 
+/* Lex a command substitution, like $(cmd). The tokens of the command are
+ * emitted between the LeftParen and the matching RightParen.
+ *
+ *	$(echo "$(pwd)")
+ *
+ *	Dollar		"$"
+ *	LeftParen	"("
+ *	Name		"echo"
+ *	Space		" "
+ *	DoubleQuote	'"'
+ *	Dollar		"$"
+ *	LeftParen	"("
+ *	Name		"pwd"
+ *	RightParen	")"
+ *	DoubleQuote	'"'
+ *	RightParen	")"
+ */
 func lexParenExpansion(lx *Lexer, nextState stateFn) stateFn {
-	return nil
+	if c := lx.nextRune(); c != '(' {
+		return lx.errorf("Expected '(' to start command substitution (got %c)", c)
+	} else {
+		lx.emit(LeftParen)
+	}
+	return composeStates(lx, lexCommandSubstitutionContents, nextState)
+}
+
+func lexCommandSubstitutionContents(lx *Lexer, nextState stateFn) stateFn {
+	c := lx.peekRune()
+	if c == eof {
+		return lx.errorf("Unclosed command substitution (expected ')')")
+	} else if c == ')' {
+		lx.nextRune()
+		lx.emit(RightParen)
+		return nextState
+	} else if c == '(' {
+		// a nested paren must be matched before the substitution ends
+		lx.nextRune()
+		lx.emit(LeftParen)
+		return composeStates(lx, lexCommandSubstitutionContents, lexCommandSubstitutionContents, nextState)
+	}
+	// lex one token of the command, and then continue
+	return lexText(lx, composeStates(lx, lexCommandSubstitutionContents, nextState))
+}
+
+/* Lex a command substitution using backquotes, like `cmd`. Within the
+ * backquotes, a backslash escapes '$', '`' and '\'. The command is emitted
+ * as a single token (with escapes removed) to be lexed separately. */
+func lexBackquotedCommand(lx *Lexer, nextState stateFn) stateFn {
+	if c := lx.nextRune(); c != '`' {
+		return lx.errorf("Expected '`' to start command substitution (got %c)", c)
+	}
+
+	var buffer bytes.Buffer
+	for {
+		c := lx.nextRune()
+		if c == eof {
+			return lx.errorf("Unclosed command substitution (expected '`')")
+		} else if c == '`' {
+			break
+		} else if c == '\\' && strings.ContainsRune("$`\\", lx.peekRune()) {
+			buffer.WriteRune(lx.nextRune())
+		} else {
+			buffer.WriteRune(c)
+		}
+	}
+	lx.emitText(BackquotedCommand, buffer.String())
+	return nextState
+}
+
+/* Lex an arithmetic expansion, like $((x + 1)). The expression is lexed like
+ * the contents of a double-quoted string, since it may contain parameter
+ * expansions and command substitutions. The expression itself is parsed
+ * later, after those are expanded.
+ *
+ *	$(($X * (2 + 1)))
+ *
+ *	Dollar			"$"
+ *	DoubleLeftParen		"(("
+ *	Dollar			"$"
+ *	Name			"X"
+ *	StringSegment		" * ("
+ *	StringSegment		"2 + 1)"
+ *	DoubleRightParen	"))"
+ */
+func lexArithmeticExpansion(lx *Lexer, nextState stateFn) stateFn {
+	if !lx.hasString("((") {
+		return lx.errorf("Expected '((' to start arithmetic expansion")
+	}
+	lx.nextRune()
+	lx.nextRune()
+	lx.emit(DoubleLeftParen)
+	return composeStates(lx, lexArithmeticContents, nextState)
+}
+
+func lexArithmeticContents(lx *Lexer, nextState stateFn) stateFn {
+	var buffer bytes.Buffer
+	for {
+		c := lx.peekRune()
+		if lx.hasString("))") {
+			lx.emitBuffer(StringSegment, buffer)
+			lx.nextRune()
+			lx.nextRune()
+			lx.emit(DoubleRightParen)
+			return nextState
+		} else if c == eof {
+			return lx.errorf("Unclosed arithmetic expansion (expected '))')")
+		} else if c == '(' {
+			lx.nextRune()
+			buffer.WriteRune(c)
+			lx.emitBuffer(StringSegment, buffer)
+			return composeStates(lx, lexArithmeticParenContents, lexArithmeticContents, nextState)
+		} else if c == '$' && lx.hasDollarExpansion() {
+			lx.emitBuffer(StringSegment, buffer)
+			return composeStates(lx, lexDollarExpansion, lexArithmeticContents, nextState)
+		} else if c == '`' {
+			lx.emitBuffer(StringSegment, buffer)
+			return composeStates(lx, lexBackquotedCommand, lexArithmeticContents, nextState)
+		} else {
+			lx.nextRune()
+			buffer.WriteRune(c)
+		}
+	}
+}
+
+/* Lex the contents of parentheses within an arithmetic expression, up to and
+ * including the matching ')'. This lets us find the "))" which ends the
+ * arithmetic expansion. */
+func lexArithmeticParenContents(lx *Lexer, nextState stateFn) stateFn {
+	var buffer bytes.Buffer
+	for {
+		c := lx.peekRune()
+		if c == ')' {
+			lx.nextRune()
+			buffer.WriteRune(c)
+			lx.emitBuffer(StringSegment, buffer)
+			return nextState
+		} else if c == eof {
+			return lx.errorf("Unclosed arithmetic expansion (expected ')')")
+		} else if c == '(' {
+			lx.nextRune()
+			buffer.WriteRune(c)
+			lx.emitBuffer(StringSegment, buffer)
+			return composeStates(lx, lexArithmeticParenContents, lexArithmeticParenContents, nextState)
+		} else if c == '$' && lx.hasDollarExpansion() {
+			lx.emitBuffer(StringSegment, buffer)
+			return composeStates(lx, lexDollarExpansion, lexArithmeticParenContents, nextState)
+		} else if c == '`' {
+			lx.emitBuffer(StringSegment, buffer)
+			return composeStates(lx, lexBackquotedCommand, lexArithmeticParenContents, nextState)
+		} else {
+			lx.nextRune()
+			buffer.WriteRune(c)
+		}
+	}
 }
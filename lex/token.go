@@ -6,12 +6,46 @@ import (
 	"unicode"
 )
 
+// the characters that start an operator, like the '|' in "a|b", which end a
+// word even when there is no blank before them
+const OPERATOR_CHARS = "|&;<>()"
+
+/* Returns true if the character continues a word. Following POSIX, a word
+ * continues until a blank or a character that starts an operator. Quotes,
+ * backslashes and '$' also end a Word token, since they begin tokens of
+ * their own. */
 func IsWordChar(c rune) bool {
-	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("./=-", c)
+	return c != eof && !unicode.IsSpace(c) && !strings.ContainsRune(OPERATOR_CHARS+"'\"`$\\", c)
 }
 
 func IsNameChar(c rune) bool {
-	return unicode.IsLetter(c) || unicode.IsDigit(c)
+	return c == '_' || isAsciiLetter(c) || isAsciiDigit(c)
+}
+
+/* Returns true if the text is a valid variable name: a letter or underscore,
+ * followed by any number of letters, digits or underscores */
+func IsName(text string) bool {
+	for j, c := range text {
+		if !IsNameChar(c) || (j == 0 && isAsciiDigit(c)) {
+			return false
+		}
+	}
+	return len(text) > 0
+}
+
+func isAsciiLetter(c rune) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isAsciiDigit(c rune) bool {
+	return '0' <= c && c <= '9'
+}
+
+// the characters that name a special parameter, like the "?" in $?
+const SPECIAL_PARAMETERS = "?@*#$!-"
+
+func IsSpecialParameter(c rune) bool {
+	return strings.ContainsRune(SPECIAL_PARAMETERS, c)
 }
 
 type Token struct {
@@ -40,6 +74,7 @@ const (
 	Word
 	AssignmentWord
 	Name
+	SpecialParameter
 	Newline
 	Number
 	Space
@@ -50,6 +85,8 @@ const (
 	OrIf
 	Semi
 	DoubleSemi
+	SemiAnd
+	DoubleSemiAnd
 
 	Less
 	DoubleLess
@@ -64,6 +101,11 @@ const (
 	SingleQuote
 	DoubleQuote
 	StringSegment
+	HereDoc
+	BackquotedCommand
+	// a character quoted by a backslash, like the '$' in \$HOME. the text is
+	// the character, without the backslash
+	EscapedChar
 
 	If
 	Then
@@ -84,6 +126,8 @@ const (
 	RightBrace
 	LeftParen
 	RightParen
+	DoubleLeftParen
+	DoubleRightParen
 	Bang
 	In
 
@@ -104,6 +148,8 @@ var OPERATORS = map[string]TokenType{
 	"||":  OrIf,
 	";":   Semi,
 	";;":  DoubleSemi,
+	";&":  SemiAnd,
+	";;&": DoubleSemiAnd,
 	"<":   Less,
 	">":   Great,
 	"<<":  DoubleLess,
@@ -114,11 +160,8 @@ var OPERATORS = map[string]TokenType{
 	"<<-": DoubleLessDash,
 	">|":  Clobber,
 	"$":   Dollar,
-	"{":   LeftBrace,
-	"}":   RightBrace,
 	"(":   LeftParen,
 	")":   RightParen,
-	"!":   Bang,
 
 	":-": ColonDash,
 	":=": ColonEquals,
@@ -136,6 +179,8 @@ var PARAMETER_EXPANSION_TTYPES = []TokenType{
 	Dash, Equals, Question, Plus,
 }
 
+// the words which the parser treats as reserved words, where the grammar
+// allows them. see Lexer.PeekReserved
 var RESERVED_WORDS = map[string]TokenType{
 	"if":       If,
 	"then":     Then,
@@ -151,6 +196,11 @@ var RESERVED_WORDS = map[string]TokenType{
 	"for":      For,
 	"function": Function,
 	"in":       In,
+	// these are reserved words rather than operators, so "a}" or "!x" is an
+	// ordinary word
+	"{": LeftBrace,
+	"}": RightBrace,
+	"!": Bang,
 }
 
 var tokenToString = map[TokenType]string{
@@ -169,6 +219,8 @@ var tokenToString = map[TokenType]string{
 	OrIf:           "OrIf",
 	Semi:           "Semi",
 	DoubleSemi:     "DoubleSemi",
+	SemiAnd:        "SemiAnd",
+	DoubleSemiAnd:  "DoubleSemiAnd",
 	Less:           "Less",
 	DoubleLess:     "DoubleLess",
 	Great:          "Great",
@@ -182,6 +234,11 @@ var tokenToString = map[TokenType]string{
 	SingleQuote:    "SingleQuote",
 	DoubleQuote:    "DoubleQuote",
 	StringSegment:  "StringSegment",
+	HereDoc:        "HereDoc",
+
+	BackquotedCommand: "BackquotedCommand",
+	EscapedChar:       "EscapedChar",
+	SpecialParameter:  "SpecialParameter",
 
 	If:       "If",
 	Then:     "Then",
@@ -197,12 +254,14 @@ var tokenToString = map[TokenType]string{
 	For:      "For",
 	Function: "Function",
 
-	LeftBrace:  "LeftBrace",
-	RightBrace: "RightBrace",
-	LeftParen:  "LeftParen",
-	RightParen: "RightParen",
-	Bang:       "Bang",
-	In:         "In",
+	LeftBrace:        "LeftBrace",
+	RightBrace:       "RightBrace",
+	LeftParen:        "LeftParen",
+	RightParen:       "RightParen",
+	DoubleLeftParen:  "DoubleLeftParen",
+	DoubleRightParen: "DoubleRightParen",
+	Bang:             "Bang",
+	In:               "In",
 
 	ColonDash:     "ColonDash",
 	ColonQuestion: "ColonQuestion",
@@ -26,17 +26,20 @@ func (e *EnvVars) Set(value string) error {
 }
 
 var (
-	filename string
-	text     string
-	debug    bool
-	env_vars EnvVars
+	filename  string
+	text      string
+	debug     bool
+	clean_env bool
+	env_vars  EnvVars
 )
 
 func init() {
 	flag.StringVar(&filename, "f", "", "The filename of a script to run")
 	flag.StringVar(&text, "t", "", "Execute the given text")
 	flag.BoolVar(&debug, "d", false, "Enable debug mode")
-	flag.Var(&env_vars, "e", "Preset environment variables")
+	flag.Var(&env_vars, "e", "Preset environment variables, overriding the inherited environment")
+	flag.BoolVar(&clean_env, "i", false, "Do not inherit the environment, so only the -e variables are set")
+	flag.BoolVar(&clean_env, "clean-env", false, "Same as -i")
 }
 
 func main() {
@@ -58,48 +61,80 @@ func main() {
 		lexer = lex.NewLexer(text)
 	}
 
+	interpreter := new_interpreter()
 	if lexer != nil {
-		if err := run_single(lexer); err != nil {
+		status, err := run_single(interpreter, lexer)
+		// the background jobs would die with psh, so let them finish
+		interpreter.WaitJobs()
+		if err != nil {
 			handle_error(err, false)
+		} else {
+			// exit with the status of the last command
+			os.Exit(status)
 		}
 	} else {
-		run_shell()
+		run_shell(interpreter)
 	}
 }
 
-func run_single(lexer *lex.Lexer) error {
+/* Create the interpreter, with the environment and the positional parameters */
+func new_interpreter() *exe.Interpreter {
+	interpreter := exe.NewInterpreter()
+	env := []string{}
+	if !clean_env {
+		env = os.Environ()
+	}
+	interpreter.InitVars(append(env, env_vars...), !clean_env)
+
+	// the arguments after the options are the positional parameters
+	interpreter.Args = flag.Args()
+	if filename != "" {
+		interpreter.Name = filename
+	} else {
+		interpreter.Name = os.Args[0]
+	}
+
+	// $- has "c" when running a command string, and "i" when interactive
+	if filename == "" && text != "" {
+		interpreter.Flags = "c"
+	} else if filename == "" {
+		interpreter.Flags = "i"
+	}
+
+	log.Printf("Environment:")
+	for _, item := range interpreter.Vars.Environ() {
+		log.Printf("  %v", item)
+	}
+	return interpreter
+}
+
+/* Run the input, and return the status of the last command */
+func run_single(interpreter *exe.Interpreter, lexer *lex.Lexer) (int, error) {
 	parser := ast.NewParser(lexer)
 	root, err := parser.Parse()
 	if err != nil {
-		return fmt.Errorf("%v\n", err)
+		return 0, fmt.Errorf("%v\n", err)
 	} else if root == nil {
-		return fmt.Errorf("Parse failure (got nil node)\n")
-	} else {
-		interpreter := exe.NewInterpreter()
-		interpreter.Env = env_vars
-
-		log.Printf("Environment:")
-		for _, item := range interpreter.Env {
-			log.Printf("  %v", item)
-		}
-
-		if err := interpreter.Interpret(root); err != nil {
-			return err
-		}
+		return 0, fmt.Errorf("Parse failure (got nil node)\n")
+	} else if err := interpreter.Interpret(root); err != nil {
+		return 0, err
 	}
-	return nil
+	return interpreter.LastStatus, nil
 }
 
-func run_shell() {
+/* Read and run commands one line at a time. Every line runs in the same
+ * interpreter, so variables, functions and the working directory carry over
+ * from one line to the next. */
+func run_shell(interpreter *exe.Interpreter) {
+	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Print("$ ")
 
-		reader := bufio.NewReader(os.Stdin)
 		if input, err := reader.ReadString('\n'); err != nil {
 			log.Fatalf("error: %v\n", err)
 		} else {
 			lexer := lex.NewLexer(strings.TrimSpace(input))
-			if err := run_single(lexer); err != nil {
+			if _, err := run_single(interpreter, lexer); err != nil {
 				handle_error(err, true)
 			}
 		}
@@ -110,7 +145,8 @@ func handle_error(err error, is_shell bool) {
 	fmt.Print(err)
 	switch e := err.(type) {
 	case exe.ExitError:
-		if !is_shell {
+		// the exit builtin gives no message, and exits even an interactive shell
+		if !is_shell || e.Error() == "" {
 			os.Exit(e.ExitCode)
 		}
 	default:
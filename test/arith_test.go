@@ -0,0 +1,153 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pglass/pshhh/arith"
+	"github.com/stretchr/testify/assert"
+)
+
+type testVars map[string]string
+
+func (v testVars) Get(name string) (string, bool) {
+	value, ok := v[name]
+	return value, ok
+}
+
+func (v testVars) Set(name, value string) error {
+	v[name] = value
+	return nil
+}
+
+type arithData struct {
+	Input string
+	Vars  testVars
+	Value int64
+	// the variables after evaluation, if the expression assigns any
+	After testVars
+	Error error
+}
+
+func run_arith_test(t *testing.T, data arithData) {
+	t.Logf("Input: %q", data.Input)
+	t.Logf("Value (expected): %v", data.Value)
+
+	vars := testVars{}
+	for k, v := range data.Vars {
+		vars[k] = v
+	}
+
+	var value int64
+	node, err := arith.Parse(data.Input)
+	if err == nil {
+		t.Logf("Parsed: %v", node)
+		value, err = arith.Eval(node, vars)
+	}
+
+	t.Logf("Error (received): %v", err)
+	t.Logf("Value (received): %v", value)
+
+	if data.Error != nil {
+		assert.Equal(t, data.Error, err)
+		return
+	}
+	assert.Nil(t, err)
+	assert.Equal(t, data.Value, value)
+	if data.After != nil {
+		assert.Equal(t, data.After, vars)
+	}
+}
+
+func TestArith(t *testing.T) {
+	for _, data := range ARITH_CASES {
+		t.Run(data.Input, func(t *testing.T) { run_arith_test(t, data) })
+	}
+}
+
+var ARITH_CASES = []arithData{
+	arithData{Input: "1", Value: 1},
+	arithData{Input: " 42 ", Value: 42},
+	arithData{Input: "0x1F", Value: 31},
+	arithData{Input: "010", Value: 8},
+	arithData{Input: "1 + 2 * 3", Value: 7},
+	arithData{Input: "(1 + 2) * 3", Value: 9},
+	arithData{Input: "10 - 4 - 3", Value: 3},
+	arithData{Input: "7 / 2", Value: 3},
+	arithData{Input: "-7 % 3", Value: -1},
+	arithData{Input: "1 << 4 >> 2", Value: 4},
+	arithData{Input: "6 & 3 | 8 ^ 1", Value: 11},
+	arithData{Input: "!0 + !5 + ~0", Value: 0},
+	arithData{Input: "-+-3", Value: 3},
+	arithData{Input: "1 < 2 && 2 <= 2 && 3 > 2 && 3 >= 4", Value: 0},
+	arithData{Input: "1 == 1 || 1 != 1", Value: 1},
+	arithData{Input: "0 ? 1 : 2 ? 3 : 4", Value: 3},
+	arithData{Input: "1, 2, 3", Value: 3},
+	arithData{Input: "x", Value: 0},
+	arithData{Input: "x + 1", Vars: testVars{"x": "41"}, Value: 42},
+	arithData{Input: "x * 2", Vars: testVars{"x": ""}, Value: 0},
+	arithData{Input: "x", Vars: testVars{"x": "y + 1", "y": "2"}, Value: 3},
+	arithData{
+		Input: "x = 5",
+		Value: 5,
+		After: testVars{"x": "5"},
+	},
+	arithData{
+		Input: "x = y = 2",
+		Value: 2,
+		After: testVars{"x": "2", "y": "2"},
+	},
+	arithData{
+		Input: "x += 2, x *= 3, x <<= 1, x -= 1",
+		Vars:  testVars{"x": "1"},
+		Value: 17,
+		After: testVars{"x": "17"},
+	},
+	arithData{
+		Input: "x++ + ++x",
+		Vars:  testVars{"x": "1"},
+		Value: 4,
+		After: testVars{"x": "3"},
+	},
+	arithData{
+		Input: "x-- - --x",
+		Vars:  testVars{"x": "5"},
+		Value: 2,
+		After: testVars{"x": "3"},
+	},
+	arithData{
+		// short circuiting skips assignments
+		Input: "0 && (x = 1), 1 || (y = 1), 1 ? 2 : (z = 3)",
+		Value: 2,
+		After: testVars{},
+	},
+	arithData{
+		Input: "1 / 0",
+		Error: fmt.Errorf("division by 0"),
+	},
+	arithData{
+		Input: "x % 0",
+		Error: fmt.Errorf("division by 0"),
+	},
+	arithData{
+		Input: "08",
+		Error: fmt.Errorf(`value too great for base (error token is "08")`),
+	},
+	arithData{
+		Input: "1 +",
+		Error: fmt.Errorf("syntax error: operand expected"),
+	},
+	arithData{
+		Input: "(1 + 2",
+		Error: fmt.Errorf(`syntax error: missing ')' (error token is end of expression)`),
+	},
+	arithData{
+		Input: "1 2",
+		Error: fmt.Errorf(`syntax error in expression (error token is "2")`),
+	},
+	arithData{
+		Input: "x",
+		Vars:  testVars{"x": "x"},
+		Error: fmt.Errorf("x: expression recursion level exceeded"),
+	},
+}
@@ -13,8 +13,14 @@ import (
 // TODO: eventually it would be nice to be able to substitute "/bin/bash" here
 var PSH_EXE = "../psh"
 
-func exec_psh(args ...string) (string, error) {
+/* Run psh with the environment env. If env is nil, psh is run with -i, so
+ * that only the variables given with -e are set. */
+func exec_psh(env []string, args ...string) (string, error) {
+	if env == nil {
+		args = append([]string{"-i"}, args...)
+	}
 	cmd := exec.Command(PSH_EXE, args...)
+	cmd.Env = env
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	err := cmd.Run()
@@ -25,6 +31,9 @@ type exeData struct {
 	Args     []string
 	Output   string
 	ExitCode int
+
+	// the environment psh inherits, if psh should not be run with -i
+	Env []string
 }
 
 func run_psh_test(t *testing.T, data exeData) {
@@ -32,7 +41,7 @@ func run_psh_test(t *testing.T, data exeData) {
 	t.Logf("ExitCode (expected): %v", data.ExitCode)
 	t.Logf("Output (expected): %v", data.Output)
 
-	out, err := exec_psh(data.Args...)
+	out, err := exec_psh(data.Env, data.Args...)
 
 	t.Logf("Error (received): %v", err)
 	t.Logf("Output (received): %v", out)
@@ -69,18 +78,17 @@ func TestPsh(t *testing.T) {
 var PSH_CASES = []exeData{
 	exeData{
 		Args:     []string{"-t", "echo"},
-		Output:   "ERROR: failed to run [echo]: no such file or directory\n",
-		ExitCode: 1,
+		Output:   "",
+		ExitCode: 127,
 	},
 	exeData{
 		Args:   []string{"-t", "echo", "-e", "PATH=/bin"},
 		Output: "\n",
 	},
-	// TODO: FAILS
-	// exeData{
-	// 	Args:   []string{"-t", `'echo'`, "-e", "PATH=/bin"},
-	// 	Output: "\n",
-	// },
+	exeData{
+		Args:   []string{"-t", `'echo'`, "-e", "PATH=/bin"},
+		Output: "\n",
+	},
 	exeData{
 		Args:   []string{"-t", `"echo"`, "-e", "PATH=/bin"},
 		Output: "\n",
@@ -101,6 +109,11 @@ var PSH_CASES = []exeData{
 		Args:   []string{"-t", `echo ${PATH} "${WUMBO}"`, "-e", "PATH=/bin", "-e", "WUMBO=mini"},
 		Output: "/bin mini\n",
 	},
+	exeData{
+		Args:     []string{"-t", `echo ${`, "-e", "PATH=/bin"},
+		Output:   "Bad substitution (expected a parameter name)\n",
+		ExitCode: 1,
+	},
 
 	/* Use Default Values (:-)
 	 *
@@ -227,14 +240,890 @@ var PSH_CASES = []exeData{
 		Args:   []string{"-t", `$FOO`, "-e", "FOO=/bin/echo wumbo"},
 		Output: "wumbo\n",
 	},
-	// TODO: fails
-	// exeData{
-	// 	Args:   []string{"-t", `$FOO`, "-e", "FOO=/bin/echo wumbo; /bin/echo mini"},
-	// 	Output: "wumbo; /bin/echo mini\n",
-	// },
-	// TODO: fails
-	// exeData{
-	// 	Args:   []string{"-t", `$FOO`, "-e", "FOO=/bin/echo $X", "-e", "X=wumbo"},
-	// 	Output: "$X\n",
-	// },
+	exeData{
+		Args:   []string{"-t", `$FOO`, "-e", "FOO=/bin/echo wumbo; /bin/echo mini"},
+		Output: "wumbo; /bin/echo mini\n",
+	},
+	exeData{
+		Args:   []string{"-t", `$FOO`, "-e", "FOO=/bin/echo $X", "-e", "X=wumbo"},
+		Output: "$X\n",
+	},
+
+	/* Pipelines */
+	exeData{
+		Args:   []string{"-t", `echo hello | cat`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "hello\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo c b a | tr " " "\n" | sort`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "a\nb\nc\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo one | cat | cat | cat; echo two`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "one\ntwo\n",
+	},
+	exeData{
+		Args:   []string{"-t", `seq 1 100000 | head -n 2`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "1\n2\n",
+	},
+	exeData{
+		Args:   []string{"-t", `{ sleep 0.2; echo b; } | cat & echo a`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "a\nb\n",
+	},
+	exeData{
+		Args:   []string{"-t", `sleep 0.1 | sleep 0.1 & test $! = $$ && echo ok`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "ok\n",
+	},
+
+	/* Redirection */
+	exeData{
+		Args:   []string{"-t", `echo hello > /dev/null`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "",
+	},
+	exeData{
+		Args: []string{"-t", `echo a > $F; echo b >> $F; echo c >>$F; cat < $F; rm $F`,
+			"-e", "PATH=/bin:/usr/bin", "-e", "F=/tmp/psh_redirect_test"},
+		Output: "a\nb\nc\n",
+	},
+	exeData{
+		Args: []string{"-t", `echo a > $F; echo b > $F; cat $F; > $F; cat $F; rm $F`,
+			"-e", "PATH=/bin:/usr/bin", "-e", "F=/tmp/psh_redirect_test_trunc"},
+		Output: "b\n",
+	},
+	exeData{
+		Args:   []string{"-t", `sh -c "echo out; echo err >&2" 2>&1`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "out\nerr\n",
+	},
+	exeData{
+		Args:   []string{"-t", `sh -c "echo out; echo err >&2" 2>&1 >/dev/null`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "err\n",
+	},
+	exeData{
+		Args:   []string{"-t", `sh -c "echo three >&3" 3>&1`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "three\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo hello 1>&2`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "",
+	},
+	exeData{
+		Args:   []string{"-t", `echo closed >&-; echo open`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "open\n",
+	},
+	exeData{
+		Args:   []string{"-t", `cat < /nonexistent/file; echo after`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "after\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo a | cat > /dev/null; echo b | cat`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "b\n",
+	},
+
+	/* Here-documents */
+	exeData{
+		Args:   []string{"-t", "cat <<EOF\nhello $X\n${X}2\nEOF", "-e", "PATH=/bin", "-e", "X=wumbo"},
+		Output: "hello wumbo\nwumbo2\n",
+	},
+	exeData{
+		Args:   []string{"-t", "cat <<'EOF'\nhello $X\nEOF", "-e", "PATH=/bin", "-e", "X=wumbo"},
+		Output: "hello $X\n",
+	},
+	exeData{
+		Args:   []string{"-t", "cat <<\"EOF\"\nhello $X\nEOF", "-e", "PATH=/bin", "-e", "X=wumbo"},
+		Output: "hello $X\n",
+	},
+	exeData{
+		Args:   []string{"-t", "cat <<EOF\n\\$X \"$X\" '$X'\nEOF", "-e", "PATH=/bin", "-e", "X=wumbo"},
+		Output: "$X \"wumbo\" 'wumbo'\n",
+	},
+	exeData{
+		Args:   []string{"-t", "cat <<-EOF\n\thello\n\t\tworld\n\tEOF\necho end", "-e", "PATH=/bin"},
+		Output: "hello\nworld\nend\n",
+	},
+	exeData{
+		Args:   []string{"-t", "cat <<A; cat <<B\na\nA\nb\nB\necho end", "-e", "PATH=/bin"},
+		Output: "a\nb\nend\n",
+	},
+	exeData{
+		Args:   []string{"-t", "cat <<EOF | sort\nb\nc\na\nEOF", "-e", "PATH=/bin:/usr/bin"},
+		Output: "a\nb\nc\n",
+	},
+	exeData{
+		Args:   []string{"-t", "cat <<EOF\nEOF", "-e", "PATH=/bin"},
+		Output: "",
+	},
+
+	/* Command substitution */
+	exeData{
+		Args:   []string{"-t", `echo $(echo hello)`, "-e", "PATH=/bin"},
+		Output: "hello\n",
+	},
+	exeData{
+		Args:   []string{"-t", "echo `echo hello`", "-e", "PATH=/bin"},
+		Output: "hello\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo "a $(echo b "$(echo c)") d"`, "-e", "PATH=/bin"},
+		Output: "a b c d\n",
+	},
+	exeData{
+		Args:   []string{"-t", "echo \"`echo $X`\"", "-e", "PATH=/bin", "-e", "X=wumbo"},
+		Output: "wumbo\n",
+	},
+	exeData{
+		Args:   []string{"-t", "echo `echo \\`echo nested\\``", "-e", "PATH=/bin"},
+		Output: "nested\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo "[$(printf "a\n\n\n")]"`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "[a]\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo "$(echo b; echo a | cat)"`, "-e", "PATH=/bin"},
+		Output: "b\na\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo ${X:-$(echo default)}`, "-e", "PATH=/bin"},
+		Output: "default\n",
+	},
+	exeData{
+		Args:   []string{"-t", "cat <<EOF\n$(echo a) `echo b`\nEOF", "-e", "PATH=/bin"},
+		Output: "a b\n",
+	},
+
+	/* Arithmetic expansion */
+	exeData{
+		Args:   []string{"-t", `echo $((1 + 2 * 3)) $(( (1 + 2) * 3 ))`, "-e", "PATH=/bin"},
+		Output: "7 9\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo $((X + 1)) $(($X * 2)) $((${X} << 1))`, "-e", "PATH=/bin", "-e", "X=3"},
+		Output: "4 6 6\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo $((X += 5)) $X $((X++)) $X $((--X))`, "-e", "PATH=/bin", "-e", "X=1"},
+		Output: "6 6 6 7 6\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo "$((COUNT = 0x10 + 010))" $COUNT`, "-e", "PATH=/bin"},
+		Output: "24 24\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo $(( $(echo 6) / 2 ))`, "-e", "PATH=/bin"},
+		Output: "3\n",
+	},
+	exeData{
+		Args:     []string{"-t", `echo $((1 / 0))`, "-e", "PATH=/bin"},
+		Output:   "psh: 1 / 0: division by 0\n",
+		ExitCode: 1,
+	},
+
+	/* If */
+	exeData{
+		Args:   []string{"-t", `if true; then echo yes; fi`, "-e", "PATH=/bin"},
+		Output: "yes\n",
+	},
+	exeData{
+		Args:   []string{"-t", `if false; then echo yes; fi`, "-e", "PATH=/bin"},
+		Output: "",
+	},
+	exeData{
+		Args:   []string{"-t", `if false; then echo yes; else echo no; fi`, "-e", "PATH=/bin"},
+		Output: "no\n",
+	},
+	exeData{
+		Args:   []string{"-t", `if false; then echo a; elif false; then echo b; elif true; then echo c; else echo d; fi`, "-e", "PATH=/bin"},
+		Output: "c\n",
+	},
+	exeData{
+		Args:   []string{"-t", `if true; false; then echo a; else echo b; echo c; fi`, "-e", "PATH=/bin"},
+		Output: "b\nc\n",
+	},
+	exeData{
+		Args:   []string{"-t", "if true\nthen\n  if false; then\n    echo a\n  else\n    echo b\n  fi\nfi\necho c", "-e", "PATH=/bin"},
+		Output: "b\nc\n",
+	},
+	exeData{
+		Args:   []string{"-t", `if echo a | grep -q b; then echo found; else echo missing; fi`, "-e", "PATH=/bin"},
+		Output: "missing\n",
+	},
+	exeData{
+		Args:   []string{"-t", `if true; then echo a; echo b; fi | sort -r`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "b\na\n",
+	},
+	exeData{
+		Args:   []string{"-t", `if true; then echo a; echo b >&2; fi >/dev/null 2>&1; echo c`, "-e", "PATH=/bin"},
+		Output: "c\n",
+	},
+	exeData{
+		Args:   []string{"-t", "if true; then cat; fi <<EOF\nhello\nEOF", "-e", "PATH=/bin"},
+		Output: "hello\n",
+	},
+	exeData{
+		Args:     []string{"-t", `if true; then fi`, "-e", "PATH=/bin"},
+		Output:   "Empty then...fi block\n",
+		ExitCode: 1,
+	},
+
+	/* While and until loops */
+	exeData{
+		Args:   []string{"-t", `while test $((N += 1)) -le 3; do echo $N; done`, "-e", "PATH=/bin:/usr/bin", "-e", "N=0"},
+		Output: "1\n2\n3\n",
+	},
+	exeData{
+		Args:   []string{"-t", `until test $((N += 1)) -gt 3; do echo $N; done`, "-e", "PATH=/bin:/usr/bin", "-e", "N=0"},
+		Output: "1\n2\n3\n",
+	},
+	exeData{
+		Args:   []string{"-t", `while false; do echo never; done; until true; do echo never; done`, "-e", "PATH=/bin"},
+		Output: "",
+	},
+	exeData{
+		Args:   []string{"-t", "while true\ndo\n  echo a\n  break\n  echo b\ndone\necho c", "-e", "PATH=/bin"},
+		Output: "a\nc\n",
+	},
+	exeData{
+		Args: []string{"-t", `while test $((N += 1)) -le 4; do if test $N -eq 2; then continue; fi; echo $N; done`,
+			"-e", "PATH=/bin:/usr/bin", "-e", "N=0"},
+		Output: "1\n3\n4\n",
+	},
+	exeData{
+		Args:   []string{"-t", `while true; do while true; do echo inner; break 2; done; echo never; done; echo outer`, "-e", "PATH=/bin"},
+		Output: "inner\nouter\n",
+	},
+	exeData{
+		Args: []string{"-t", `while test $((N += 1)) -le 2; do until false; do echo $N; continue 2; done; echo never; done`,
+			"-e", "PATH=/bin:/usr/bin", "-e", "N=0"},
+		Output: "1\n2\n",
+	},
+	exeData{
+		Args:   []string{"-t", `while true; do break 5; done; echo ok`, "-e", "PATH=/bin"},
+		Output: "ok\n",
+	},
+	exeData{
+		Args:   []string{"-t", `while test $((N += 1)) -le 3; do echo $N; done | sort -r`, "-e", "PATH=/bin:/usr/bin", "-e", "N=0"},
+		Output: "3\n2\n1\n",
+	},
+	exeData{
+		Args:   []string{"-t", `while test $((N += 1)) -le 3; do echo $N; done > /dev/null; echo ok`, "-e", "PATH=/bin:/usr/bin", "-e", "N=0"},
+		Output: "ok\n",
+	},
+	exeData{
+		Args:   []string{"-t", `break; continue; echo ok`, "-e", "PATH=/bin"},
+		Output: "ok\n",
+	},
+	exeData{
+		Args:     []string{"-t", `while true; do echo a`, "-e", "PATH=/bin"},
+		Output:   "Unexpected end of input (expected any of [Done])\n",
+		ExitCode: 1,
+	},
+
+	/* For loops */
+	exeData{
+		Args:   []string{"-t", `for x in a b c; do echo $x; done`, "-e", "PATH=/bin"},
+		Output: "a\nb\nc\n",
+	},
+	exeData{
+		Args:   []string{"-t", "for x in $A \"$B\" $(echo c)\ndo\n  echo $x\ndone", "-e", "PATH=/bin", "-e", "A=a", "-e", "B=b"},
+		Output: "a\nb\nc\n",
+	},
+	exeData{
+		Args:   []string{"-t", `for x in a b; do echo $x; done; echo $x`, "-e", "PATH=/bin"},
+		Output: "a\nb\nb\n",
+	},
+	exeData{
+		Args:   []string{"-t", `for x in; do echo never; done; for x; do echo never; done; echo ok`, "-e", "PATH=/bin"},
+		Output: "ok\n",
+	},
+	exeData{
+		Args:   []string{"-t", `for x in a b c d; do if test $x = b; then continue; elif test $x = d; then break; fi; echo $x; done`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "a\nc\n",
+	},
+	exeData{
+		Args:   []string{"-t", `for x in 1 2; do for y in a b; do echo $x $y; continue 2; done; done`, "-e", "PATH=/bin"},
+		Output: "1 a\n2 a\n",
+	},
+	exeData{
+		Args:   []string{"-t", `for x in a b c; do echo $x; done | sort -r`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "c\nb\na\n",
+	},
+	exeData{
+		Args:   []string{"-t", `for x in a b; do echo $x; done > /dev/null; echo ok`, "-e", "PATH=/bin"},
+		Output: "ok\n",
+	},
+
+	/* Case */
+	exeData{
+		Args:   []string{"-t", `case foo in f*) echo star;; foo) echo exact;; esac`, "-e", "PATH=/bin"},
+		Output: "star\n",
+	},
+	exeData{
+		Args:   []string{"-t", `case $X in -h|--help) echo help;; *) echo other;; esac`, "-e", "PATH=/bin", "-e", "X=--help"},
+		Output: "help\n",
+	},
+	exeData{
+		Args:   []string{"-t", "case x in\n  [abc]) echo no ;;\n  (?)\n    echo yes\n    ;;\nesac", "-e", "PATH=/bin"},
+		Output: "yes\n",
+	},
+	exeData{
+		Args:   []string{"-t", `case b in a) echo a;; b) echo b;& c) echo c;; d) echo d; esac`, "-e", "PATH=/bin"},
+		Output: "b\nc\n",
+	},
+	exeData{
+		Args:   []string{"-t", `case abc in a*) echo 1;;& *c) echo 2;;& x) echo 3;; *) echo 4;; esac`, "-e", "PATH=/bin"},
+		Output: "1\n2\n4\n",
+	},
+	exeData{
+		Args:   []string{"-t", `case z in y) echo never;; esac; case z in z) esac; echo ok`, "-e", "PATH=/bin"},
+		Output: "ok\n",
+	},
+	exeData{
+		Args: []string{"-t", `for x in 1 a 2; do case $x in [[:digit:]]) echo $x;; *) continue;; esac; done | sort -r`,
+			"-e", "PATH=/bin:/usr/bin"},
+		Output: "2\n1\n",
+	},
+	exeData{
+		Args:     []string{"-t", `case a in a) echo a`, "-e", "PATH=/bin"},
+		Output:   "Unexpected end of input (expected any of [DoubleSemi SemiAnd DoubleSemiAnd Esac])\n",
+		ExitCode: 1,
+	},
+
+	/* Functions */
+	exeData{
+		Args:   []string{"-t", `cd() { echo mine $1; }; cd /; export() { echo theirs; }; export A=1; echo $A`, "-e", "PATH=/bin"},
+		Output: "mine /\n1\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { sleep 0.1; echo in; }; f > /tmp/psh_bg_out & sleep 0.5; cat /tmp/psh_bg_out`, "-e", "PATH=/bin"},
+		Output: "in\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { sleep 0.2; echo late; }; { f & } > /tmp/psh_bg_group_out; echo x > /dev/null; sleep 0.5; cat /tmp/psh_bg_group_out`, "-e", "PATH=/bin"},
+		Output: "late\n",
+	},
+	exeData{
+		Args:   []string{"-t", `greet() { echo hello $1 $2; }; greet a b; greet c`, "-e", "PATH=/bin"},
+		Output: "hello a b\nhello c\n",
+	},
+	exeData{
+		Args:   []string{"-t", "function f {\n  echo a\n  return\n  echo never\n}\nfunction g() { f; }\ng", "-e", "PATH=/bin"},
+		Output: "a\n",
+	},
+	exeData{
+		Args: []string{"-t", `f() { return $1; }; if f 0; then echo zero; fi; if f 3; then echo never; else echo three; fi`,
+			"-e", "PATH=/bin"},
+		Output: "zero\nthree\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { while true; do return 4; done; echo never; }; f; echo ok`, "-e", "PATH=/bin"},
+		Output: "ok\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { local X=inner Y=new; echo $X $Y; g; }; g() { echo $X; }; f; echo $X ${Y-unset}`, "-e", "PATH=/bin", "-e", "X=outer"},
+		Output: "inner new\ninner\nouter unset\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { local X; echo "[$X]"; }; f; echo $X`, "-e", "PATH=/bin", "-e", "X=outer"},
+		Output: "[]\nouter\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { echo $1; }; for x in a b; do f $x; done; echo $1`, "-e", "PATH=/bin"},
+		Output: "a\nb\n\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { break; echo still; }; for x in 1 2; do f; echo $x; done`, "-e", "PATH=/bin"},
+		Output: "still\n1\nstill\n2\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { echo a; echo b; } > /dev/null; f; echo ok`, "-e", "PATH=/bin"},
+		Output: "ok\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { echo b; echo a; }; f | sort; echo "$(f)"`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "a\nb\nb\na\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() if true; then echo if-body; fi; f`, "-e", "PATH=/bin"},
+		Output: "if-body\n",
+	},
+	exeData{
+		Args: []string{"-t", `fact() { if test $1 -le 1; then echo 1; else echo $(($1 * $(fact $(($1 - 1))))); fi; }; fact 5`,
+			"-e", "PATH=/bin:/usr/bin"},
+		Output: "120\n",
+	},
+	exeData{
+		Args:   []string{"-t", `return; local x; echo ok`, "-e", "PATH=/bin"},
+		Output: "ok\n",
+	},
+	exeData{
+		Args:     []string{"-t", `f() echo x`, "-e", "PATH=/bin"},
+		Output:   "Syntax error near Name(\"echo\" 4, 1) (expected a compound command)\n",
+		ExitCode: 1,
+	},
+
+	/* Subshells and brace groups */
+	exeData{
+		Args:   []string{"-t", `{ sleep 0.2; echo bg; } & test $! = $$ && echo fg`, "-e", "PATH=/bin"},
+		Output: "fg\nbg\n",
+	},
+	exeData{
+		Args:   []string{"-t", `{ echo a; echo b; } | sort -r; { echo c; } > /dev/null`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "b\na\n",
+	},
+	exeData{
+		Args:   []string{"-t", `(echo a; echo b) | sort -r; (echo c) > /dev/null; ( (echo d) )`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "b\na\nd\n",
+	},
+	exeData{
+		Args:   []string{"-t", `cd /tmp; (cd /; pwd); pwd; { cd /; }; pwd`, "-e", "PATH=/bin"},
+		Output: "/\n/tmp\n/\n",
+	},
+	exeData{
+		Args:   []string{"-t", `(f() { echo inner; }; f; local X); f() { echo outer; }; f`, "-e", "PATH=/bin"},
+		Output: "inner\nouter\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { (return 3; echo never); echo after; }; f`, "-e", "PATH=/bin"},
+		Output: "after\n",
+	},
+	exeData{
+		Args:   []string{"-t", `cd /tmp; cd /; cd - > /dev/null; pwd; echo $PWD $OLDPWD`, "-e", "PATH=/bin"},
+		Output: "/tmp\n/tmp /\n",
+	},
+	exeData{
+		Args:   []string{"-t", `cd; pwd; cd /nonexistent; pwd`, "-e", "PATH=/bin", "-e", "HOME=/tmp"},
+		Output: "/tmp\n/tmp\n",
+	},
+	exeData{
+		Args:     []string{"-t", `(echo a`, "-e", "PATH=/bin"},
+		Output:   "Unexpected end of input (expected any of [RightParen])\n",
+		ExitCode: 1,
+	},
+
+	/* Exit statuses */
+	exeData{
+		Args:   []string{"-t", `false; echo $?; true; echo "${?}"`, "-e", "PATH=/bin"},
+		Output: "1\n0\n",
+	},
+	exeData{
+		Args:   []string{"-t", `x=$(false); echo $?; echo $(exit 2) $?; $(exit 3); echo $?; false; x=1; echo $?`, "-e", "PATH=/bin"},
+		Output: "1\n2\n3\n0\n",
+	},
+	exeData{
+		Args:   []string{"-t", `true; echo $(exit 5)x; echo $?`, "-e", "PATH=/bin"},
+		Output: "x\n0\n",
+	},
+	exeData{
+		Args:     []string{"-t", `echo ok; false`, "-e", "PATH=/bin"},
+		Output:   "ok\n",
+		ExitCode: 1,
+	},
+	exeData{
+		Args:   []string{"-t", `nosuchcommand; echo $?; /etc/passwd; echo $?`, "-e", "PATH=/bin"},
+		Output: "127\n126\n",
+	},
+	exeData{
+		Args:   []string{"-t", `sh -c "kill -9 \$\$"; echo $?`, "-e", "PATH=/bin"},
+		Output: "137\n",
+	},
+	exeData{
+		Args:   []string{"-t", `true && echo yes`, "-e", "PATH=/bin"},
+		Output: "yes\n",
+	},
+	exeData{
+		Args:     []string{"-t", `false && echo never`, "-e", "PATH=/bin"},
+		Output:   "",
+		ExitCode: 1,
+	},
+	exeData{
+		Args:   []string{"-t", `false || echo no`, "-e", "PATH=/bin"},
+		Output: "no\n",
+	},
+	exeData{
+		Args:   []string{"-t", `false && echo never || echo fallback`, "-e", "PATH=/bin"},
+		Output: "fallback\n",
+	},
+
+	/* And-or lists */
+	exeData{
+		Args:   []string{"-t", `echo a; false && echo never; true && echo yes; false || echo no; true || echo never`, "-e", "PATH=/bin"},
+		Output: "a\nyes\nno\n",
+	},
+	exeData{
+		Args:   []string{"-t", "false || false && echo never || echo last\ntrue &&\n  echo newline", "-e", "PATH=/bin"},
+		Output: "last\nnewline\n",
+	},
+	exeData{
+		Args:   []string{"-t", `if true && false; then echo never; else echo other; fi`, "-e", "PATH=/bin"},
+		Output: "other\n",
+	},
+	exeData{
+		Args:   []string{"-t", `while test $((N += 1)) -le 3 && test $N -ne 2; do echo $N; done`, "-e", "PATH=/bin:/usr/bin", "-e", "N=0"},
+		Output: "1\n",
+	},
+	exeData{
+		Args:   []string{"-t", `for x in 1 2 3; do test $x = 2 && continue; echo $x; done`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "1\n3\n",
+	},
+	exeData{
+		Args:   []string{"-t", `echo x | grep -q y || echo nomatch | tr a-z A-Z`, "-e", "PATH=/bin:/usr/bin"},
+		Output: "NOMATCH\n",
+	},
+	exeData{
+		Args:     []string{"-t", `&& echo x`, "-e", "PATH=/bin"},
+		Output:   "Syntax error near AndIf(\"&&\" 0, 1)\n",
+		ExitCode: 1,
+	},
+	exeData{
+		Args:     []string{"-t", `true &&`, "-e", "PATH=/bin"},
+		Output:   "Syntax error near \"&&\" (expected command)\n",
+		ExitCode: 1,
+	},
+
+	/* Pipeline negation */
+	exeData{
+		Args:   []string{"-t", `! true; echo $?; ! false; echo $?`, "-e", "PATH=/bin"},
+		Output: "1\n0\n",
+	},
+	exeData{
+		Args:   []string{"-t", `! echo a | grep -q b && echo nomatch; ! echo a | grep -q a || echo match`, "-e", "PATH=/bin"},
+		Output: "nomatch\nmatch\n",
+	},
+	exeData{
+		Args:   []string{"-t", `if ! grep -q x /dev/null; then echo notfound; fi`, "-e", "PATH=/bin"},
+		Output: "notfound\n",
+	},
+	exeData{
+		Args:   []string{"-t", `while ! test $((N += 1)) -gt 2; do echo $N; done`, "-e", "PATH=/bin:/usr/bin", "-e", "N=0"},
+		Output: "1\n2\n",
+	},
+	exeData{
+		Args:   []string{"-t", `! cd /tmp; pwd`, "-e", "PATH=/bin"},
+		Output: "/tmp\n",
+	},
+	exeData{
+		Args:     []string{"-t", `! true`, "-e", "PATH=/bin"},
+		Output:   "",
+		ExitCode: 1,
+	},
+	exeData{
+		Args:     []string{"-t", `!`, "-e", "PATH=/bin"},
+		Output:   "Syntax error near \"!\" (expected command)\n",
+		ExitCode: 1,
+	},
+	exeData{
+		Args:     []string{"-t", `echo a; exit 3; echo never`, "-e", "PATH=/bin"},
+		Output:   "a\n",
+		ExitCode: 3,
+	},
+	exeData{
+		Args:     []string{"-t", `false; exit`, "-e", "PATH=/bin"},
+		Output:   "",
+		ExitCode: 1,
+	},
+	exeData{
+		Args:     []string{"-t", `f() { exit 5; }; f; echo never`, "-e", "PATH=/bin"},
+		Output:   "",
+		ExitCode: 5,
+	},
+	exeData{
+		Args:   []string{"-t", `(exit 4); echo $?; exit 2 | cat; echo $?; echo "$(echo a; exit 6; echo b)"`, "-e", "PATH=/bin"},
+		Output: "4\n0\na\n",
+	},
+	exeData{
+		Args:     []string{"-t", `exit foo`, "-e", "PATH=/bin"},
+		Output:   "",
+		ExitCode: 2,
+	},
+
+	/* Variable assignment */
+	exeData{
+		Args:   []string{"-t", `FOO=bar; echo $FOO`, "-e", "PATH=/bin"},
+		Output: "bar\n",
+	},
+	exeData{
+		Args:   []string{"-t", `A=1 B="x $A" C='y z'; echo $B; echo $C`, "-e", "PATH=/bin"},
+		Output: "x 1\ny z\n",
+	},
+	exeData{
+		Args:   []string{"-t", `FOO=bar sh -c "echo \$FOO"; echo "[$FOO]"`, "-e", "PATH=/bin"},
+		Output: "bar\n[]\n",
+	},
+	exeData{
+		Args:   []string{"-t", `V=old; V=new true; echo $V`, "-e", "PATH=/bin"},
+		Output: "old\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { echo $V; }; V=1 f; echo "[$V]"`, "-e", "PATH=/bin"},
+		Output: "1\n[]\n",
+	},
+	exeData{
+		Args:   []string{"-t", `A=$(echo hi) B=; echo $A "[$B]" c=d`, "-e", "PATH=/bin"},
+		Output: "hi [] c=d\n",
+	},
+
+	/* Export, unset and readonly */
+	exeData{
+		Args:   []string{"-t", `A=1; sh -c "echo [\$A]"; export A; sh -c "echo [\$A]"`, "-e", "PATH=/bin"},
+		Output: "[]\n[1]\n",
+	},
+	exeData{
+		Args:   []string{"-t", `(export S=1); sh -c "echo [\$S]"`, "-e", "PATH=/bin"},
+		Output: "[]\n",
+	},
+	exeData{
+		Args:   []string{"-t", `unset PWD SHLVL; export B=2 C; C='a"b $c'; export -p`, "-e", "PATH=/bin"},
+		Output: "export B=\"2\"\nexport C=\"a\\\"b \\$c\"\nexport PATH=\"/bin\"\n",
+	},
+	exeData{
+		Args:   []string{"-t", `export C='a"b $c'; export -p > /tmp/psh_export_test; unset C; sh -c ". /tmp/psh_export_test; echo \"\$C\""`, "-e", "PATH=/bin"},
+		Output: "a\"b $c\n",
+	},
+	exeData{
+		Args:     []string{"-t", `X=1; unset X; echo ${X-unset}; f() { echo f; }; unset -f f; f`, "-e", "PATH=/bin"},
+		Output:   "unset\n",
+		ExitCode: 127,
+	},
+	exeData{
+		Args:   []string{"-t", `readonly R=1; export R=2; echo $? $R; unset R; echo $? $R; readonly -p | grep -v PPID`, "-e", "PATH=/bin"},
+		Output: "1 1\n1 1\nreadonly R=\"1\"\n",
+	},
+	exeData{
+		Args:     []string{"-t", `readonly R=1; R=2; echo never`, "-e", "PATH=/bin"},
+		Output:   "error: R: readonly variable\n",
+		ExitCode: 1,
+	},
+	exeData{
+		Args:   []string{"-t", `f() { local -i n=5; n="n*2"; echo $n; local Y; echo ${Y-unset}; }; Y=g; f; echo $Y`, "-e", "PATH=/bin"},
+		Output: "10\nunset\ng\n",
+	},
+	exeData{
+		Args:   []string{"-t", `f() { X=1 local y=2; echo $y; }; f; echo "[$y]"`, "-e", "PATH=/bin"},
+		Output: "2\n[]\n",
+	},
+
+	/* The inherited environment */
+	exeData{
+		Args:   []string{"-t", `echo $A $B; sh -c "echo \$A"`, "-e", "B=3"},
+		Output: "1 3\n1\n",
+		Env:    []string{"A=1", "B=2", "PATH=/bin"},
+	},
+	exeData{
+		Args:   []string{"-t", `echo $PATH $SHLVL "[$IFS]"; cd /; echo $PWD`},
+		Output: "/usr/local/bin:/usr/bin:/bin 3 [ \t\n]\n/\n",
+		Env:    []string{"SHLVL=2"},
+	},
+	exeData{
+		Args:   []string{"-i", "-t", `echo ${A-unset} $SHLVL`, "-e", "PATH=/bin"},
+		Output: "unset 1\n",
+		Env:    []string{"A=1", "SHLVL=4"},
+	},
+	exeData{
+		Args:   []string{"-i", "-t", `/usr/bin/env; /bin/echo $SHLVL $PATH`, "-e", "B=2"},
+		Output: "B=2\n1 /usr/local/bin:/usr/bin:/bin\n",
+		Env:    []string{"A=1"},
+	},
+	exeData{
+		Args:   []string{"--clean-env", "-t", `test $PPID -gt 0 && echo ok`, "-e", "PATH=/bin"},
+		Output: "ok\n",
+		Env:    []string{"A=1"},
+	},
+
+	/* Positional parameters */
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `echo $# $1 "$2" ${10}`, "a", "b c", "3", "4", "5", "6", "7", "8", "9", "ten"},
+		Output: "10 a b c ten\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `for x in "$@"; do echo "[$x]"; done; for x in "<$@>"; do echo "$x"; done`, "a", "b c"},
+		Output: "[a]\n[b c]\n<a\nb c>\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `for x in "$*"; do echo "[$x]"; done; IFS=-; echo "$*"; IFS=; echo "$*"`, "a", "b c"},
+		Output: "[a b c]\na-b c\nab c\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `for x in "$@"; do echo never; done; echo "[$@]" $#`},
+		Output: "[] 0\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `for x; do echo $x; done`, "a", "b"},
+		Output: "a\nb\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `shift; echo $# "$@"; shift 2; echo $? $#; shift 2; echo $? $#`, "a", "b", "c", "d"},
+		Output: "3 b c d\n0 1\n1 1\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `set -- x "y z"; echo $#; for a; do echo "[$a]"; done`, "a"},
+		Output: "2\n[x]\n[y z]\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `f() { echo $# "$@"; shift; echo "$*"; }; f 1 2 3; echo $1`, "a"},
+		Output: "3 1 2 3\n2 3\na\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `f() { echo $0; }; f`},
+		Output: PSH_EXE + "\n",
+	},
+
+	/* Special parameters */
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `test $$ -gt 0 && (test "$(echo $$)" = $$) && test $(sh -c "echo \$PPID") = $$ && echo ok`},
+		Output: "ok\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `echo "[${!-none}]"; sleep 0 & test $! -gt 0 && echo ok`},
+		Output: "[none]\nok\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `f() { return 3; }; f & test $! = $$ && echo ok`},
+		Output: "ok\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `sleep 0.1 & p=$!; sleep 0.3; test -e /proc/$p || echo reaped`},
+		Output: "reaped\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `echo "[$-]"`},
+		Output: "[c]\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `echo a b; echo $_ "${_}"`},
+		Output: "a b\nb b\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", "echo $LINENO\nf() {\n  echo \"${LINENO}\"\n}\nf; echo $(echo $LINENO)"},
+		Output: "1\n3\n5\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", "echo a\n\necho `echo $LINENO`\ncat <<EOF\n$LINENO\nEOF"},
+		Output: "a\n3\n5\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `echo $; echo "a $" a$ $% "$"x ${U:-$}`},
+		Output: "$\na $ a$ $% $x $\n",
+	},
+
+	/* Field splitting */
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `FLAGS="-n  a"; args=$FLAGS; echo $args; echo "$args"`},
+		Output: "a-n  a\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `unset X; set -- ${X:-"a b"}; echo $#; Y="1 2"; set -- ${X:-$Y} ${X:-"$Y"} ${Y:+"$Y"} ${X-""}; echo $# "$3" "$4" "[$5]"`},
+		Output: "1\n5 1 2 1 2 []\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", "X=\" a  b\tc \"; for x in $X; do echo \"[$x]\"; done; for x in \"$X\"; do echo \"[$x]\"; done"},
+		Output: "[a]\n[b]\n[c]\n[ a  b\tc ]\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `IFS=":"; X="a::b:"; for x in $X; do echo "[$x]"; done`},
+		Output: "[a]\n[]\n[b]\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `IFS=" :"; X=" a : b  :c : "; for x in $X; do echo "[$x]"; done`},
+		Output: "[a]\n[b]\n[c]\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `IFS=; X="a b"; for x in $X; do echo "[$x]"; done`},
+		Output: "[a b]\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `E=; for x in $E; do echo never; done; for x in "$E" ""; do echo "[$x]"; done; echo $E a`},
+		Output: "[]\n[]\na\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", "for x in $(echo 1 2) \"$(echo 3 4)\" `echo 5 6` $((7)); do echo \"[$x]\"; done"},
+		Output: "[1]\n[2]\n[3 4]\n[5]\n[6]\n[7]\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `for x in $@; do echo "[$x]"; done; IFS=":"; for x in $*; do echo "<$x>"; done`, "a b", "", "c:d"},
+		Output: "[a]\n[b]\n[c:d]\n<a b>\n<c>\n<d>\n",
+	},
+
+	/* Words */
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `echo a+b user@host key:value 50% ~/x foo_bar a}b !x {x`},
+		Output: "a+b user@host key:value 50% ~/x foo_bar a}b !x {x\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `foo_bar=1; echo $foo_bar ${foo_bar} ${no_such:-d_f}; { echo a|cat; }; ! false&&echo b`},
+		Output: "1 1 d_f\na\nb\n",
+	},
+
+	/* Words built from adjacent segments */
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-e", "HOME=/h", "-t", `X=f; echo --prefix="$HOME"/opt 'a'"b"c ${X}.txt $X.txt a$X"b"`},
+		Output: "--prefix=/h/opt abc f.txt f.txt afb\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `E=; for x in ""$E ''$E $E; do echo "[$x]"; done; Y="x y"z; echo "$Y" ${U:-"d f"}g`},
+		Output: "[]\n[]\nx yz d fg\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `case ab in "a"*) echo a;; esac; case ab in "a*") echo b;; "a"b) echo c;; esac`},
+		Output: "a\nc\n",
+	},
+
+	/* Reserved words as arguments */
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `echo done for in if then fi { } ! esac; X=do; echo $X`},
+		Output: "done for in if then fi { } ! esac\ndo\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `for in in in do; do echo $in; done; for x in a do b; do echo $x; done`},
+		Output: "in\ndo\na\ndo\nb\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `case in in in) echo a;; esac; case esac in (esac) echo b;; esac; f() { echo c done; }; f`},
+		Output: "a\nb\nc done\n",
+	},
+	exeData{
+		Args:     []string{"-e", "PATH=/bin", "-t", `if true; then echo x fi`},
+		Output:   "Unexpected end of input (expected any of [Elif Else Fi])\n",
+		ExitCode: 1,
+	},
+
+	/* Backslash escaping */
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-e", "HOME=/h", "-t", `echo a\ b \$HOME \* x\"y\'z \\; \echo esc\aped`},
+		Output: "a b $HOME * x\"y'z \\\nescaped\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", "echo lo\\\nng \\\nnext; X=a\\ b; for x in $X \"$X\"; do echo \"[$x]\"; done"},
+		Output: "long next\n[a]\n[b]\n[a b]\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `case "a*" in a\*) echo a;; esac; case ab in a\*) echo b;; *) echo c;; esac`},
+		Output: "a\nc\n",
+	},
+	exeData{
+		Args:     []string{"-e", "PATH=/bin", "-t", `\if true`},
+		Output:   "",
+		ExitCode: 127,
+	},
+
+	/* Pathname expansion */
+	exeData{
+		Args:   []string{"-e", "PATH=/bin:/usr/bin", "-e", "D=/tmp/pshglob", "-t", `rm -rf $D; mkdir $D; cd $D; touch b.o a.o c.txt .h.o; echo *.o; echo .*.o; echo [a-b].o [!a].o ?.txt`},
+		Output: "a.o b.o\n.h.o\na.o b.o b.o c.txt\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin:/usr/bin", "-e", "D=/tmp/pshglob", "-t", `rm -rf $D; mkdir $D; cd $D; touch a.o; echo *.z "*.o" '*.o'; X="*.o"; echo $X "$X"`},
+		Output: "*.z *.o *.o\na.o *.o\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin:/usr/bin", "-e", "D=/tmp/pshglob", "-t", `rm -rf $D; mkdir $D; cd $D; mkdir build src; touch build/y.o build/x.o src/a.c; echo */*.o; echo */; echo /tmp/pshglob/s*/*; rm -f build/*.o; echo build/*`},
+		Output: "build/x.o build/y.o\nbuild/ src/\n/tmp/pshglob/src/a.c\nbuild/*\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin:/usr/bin", "-t", `echo [a|tr a x]; echo [;echo ]`},
+		Output: "[x\n[\n]\n",
+	},
+	exeData{
+		Args:   []string{"-e", "PATH=/bin", "-t", `case "*" in "*") echo a;; esac; case ab in "a*") echo b;; a*) echo c;; esac`},
+		Output: "a\nc\n",
+	},
 }
@@ -59,6 +59,57 @@ var LEX_CASES = []lexData{
 			lex.Token{lex.EOF, "", 30, 1},
 		},
 	},
+	lexData{
+		Input: "a+b x_y=1 50% {x }",
+		Tokens: []lex.Token{
+			lex.Token{lex.Name, "a+b", 0, 1},
+			lex.Token{lex.Space, " ", 3, 1},
+			lex.Token{lex.AssignmentWord, "x_y=1", 4, 1},
+			lex.Token{lex.Space, " ", 9, 1},
+			lex.Token{lex.Word, "50%", 10, 1},
+			lex.Token{lex.Space, " ", 13, 1},
+			lex.Token{lex.Name, "{x", 14, 1},
+			lex.Token{lex.Space, " ", 16, 1},
+			lex.Token{lex.Name, "}", 17, 1},
+			lex.Token{lex.EOF, "", 18, 1},
+		},
+	},
+	lexData{
+		Input: "echo a|b;c",
+		Tokens: []lex.Token{
+			lex.Token{lex.Name, "echo", 0, 1},
+			lex.Token{lex.Space, " ", 4, 1},
+			lex.Token{lex.Name, "a", 5, 1},
+			lex.Token{lex.Pipe, "|", 6, 1},
+			lex.Token{lex.Name, "b", 7, 1},
+			lex.Token{lex.Semi, ";", 8, 1},
+			lex.Token{lex.Name, "c", 9, 1},
+			lex.Token{lex.EOF, "", 10, 1},
+		},
+	},
+	lexData{
+		Input: "echo a\\ b\\\nc",
+		Tokens: []lex.Token{
+			lex.Token{lex.Name, "echo", 0, 1},
+			lex.Token{lex.Space, " ", 4, 1},
+			lex.Token{lex.Name, "a", 5, 1},
+			lex.Token{lex.EscapedChar, " ", 6, 1},
+			lex.Token{lex.Name, "b", 8, 1},
+			lex.Token{lex.Name, "c", 11, 2},
+			lex.Token{lex.EOF, "", 12, 2},
+		},
+	},
+	lexData{
+		Input: "A=1 bc= --d=2",
+		Tokens: []lex.Token{
+			lex.Token{lex.AssignmentWord, "A=1", 0, 1},
+			lex.Token{lex.Space, " ", 3, 1},
+			lex.Token{lex.AssignmentWord, "bc=", 4, 1},
+			lex.Token{lex.Space, " ", 7, 1},
+			lex.Token{lex.Name, "--d=2", 8, 1},
+			lex.Token{lex.EOF, "", 13, 1},
+		},
+	},
 	lexData{
 		Input: "1abc 234a 5b7",
 		Tokens: []lex.Token{
@@ -131,21 +182,21 @@ var LEX_CASES = []lexData{
 	lexData{
 		Input: "for var in items; do echo; done",
 		Tokens: []lex.Token{
-			lex.Token{lex.For, "for", 0, 1},
+			lex.Token{lex.Name, "for", 0, 1},
 			lex.Token{lex.Space, " ", 3, 1},
 			lex.Token{lex.Name, "var", 4, 1},
 			lex.Token{lex.Space, " ", 7, 1},
-			lex.Token{lex.In, "in", 8, 1},
+			lex.Token{lex.Name, "in", 8, 1},
 			lex.Token{lex.Space, " ", 10, 1},
 			lex.Token{lex.Name, "items", 11, 1},
 			lex.Token{lex.Semi, ";", 16, 1},
 			lex.Token{lex.Space, " ", 17, 1},
-			lex.Token{lex.Do, "do", 18, 1},
+			lex.Token{lex.Name, "do", 18, 1},
 			lex.Token{lex.Space, " ", 20, 1},
 			lex.Token{lex.Name, "echo", 21, 1},
 			lex.Token{lex.Semi, ";", 25, 1},
 			lex.Token{lex.Space, " ", 26, 1},
-			lex.Token{lex.Done, "done", 27, 1},
+			lex.Token{lex.Name, "done", 27, 1},
 			lex.Token{lex.EOF, "", 31, 1},
 		},
 	},
@@ -192,9 +243,10 @@ var LEX_CASES = []lexData{
 		},
 	},
 	lexData{
+		// a '$' that starts no expansion is an ordinary character
 		Input: "$",
 		Tokens: []lex.Token{
-			lex.Token{lex.Dollar, "$", 0, 1},
+			lex.Token{lex.Word, "$", 0, 1},
 			lex.Token{lex.EOF, "", 1, 1},
 		},
 	},
@@ -238,7 +290,7 @@ var LEX_CASES = []lexData{
 		Input: `"$"`,
 		Tokens: []lex.Token{
 			lex.Token{lex.DoubleQuote, `"`, 0, 1},
-			lex.Token{lex.Dollar, "$", 1, 1},
+			lex.Token{lex.StringSegment, "$", 1, 1},
 			lex.Token{lex.DoubleQuote, `"`, 2, 1},
 			lex.Token{lex.EOF, "", 3, 1},
 		},
@@ -307,10 +359,7 @@ var LEX_CASES = []lexData{
 		Input: `"$\$$\$"`,
 		Tokens: []lex.Token{
 			lex.Token{lex.DoubleQuote, `"`, 0, 1},
-			lex.Token{lex.Dollar, `$`, 1, 1},
-			lex.Token{lex.StringSegment, `$`, 2, 1},
-			lex.Token{lex.Dollar, `$`, 4, 1},
-			lex.Token{lex.StringSegment, `$`, 5, 1},
+			lex.Token{lex.StringSegment, `$$$$`, 1, 1},
 			lex.Token{lex.DoubleQuote, `"`, 7, 1},
 			lex.Token{lex.EOF, "", 8, 1},
 		},
@@ -418,4 +467,161 @@ var LEX_CASES = []lexData{
 			lex.Token{lex.EOF, "", 19, 1},
 		},
 	},
+	lexData{
+		Input: "cat <<EOF\nhi\nEOF\n",
+		Tokens: []lex.Token{
+			lex.Token{lex.Name, "cat", 0, 1},
+			lex.Token{lex.Space, " ", 3, 1},
+			lex.Token{lex.DoubleLess, "<<", 4, 1},
+			lex.Token{lex.Word, "EOF", 6, 1},
+			lex.Token{lex.HereDoc, "hi\n", 10, 2},
+			lex.Token{lex.Newline, "\n", 9, 1},
+			lex.Token{lex.EOF, "", 17, 4},
+		},
+	},
+	lexData{
+		Input: "cat <<-'E' >out\n\thi\n\tE",
+		Tokens: []lex.Token{
+			lex.Token{lex.Name, "cat", 0, 1},
+			lex.Token{lex.Space, " ", 3, 1},
+			lex.Token{lex.DoubleLessDash, "<<-", 4, 1},
+			lex.Token{lex.Word, "'E'", 7, 1},
+			lex.Token{lex.HereDoc, "hi\n", 16, 2},
+			lex.Token{lex.Space, " ", 10, 1},
+			lex.Token{lex.Great, ">", 11, 1},
+			lex.Token{lex.Name, "out", 12, 1},
+			lex.Token{lex.Newline, "\n", 15, 1},
+			lex.Token{lex.EOF, "", 22, 3},
+		},
+	},
+	lexData{
+		Input: `"$(echo ")")"`,
+		Tokens: []lex.Token{
+			lex.Token{lex.DoubleQuote, `"`, 0, 1},
+			lex.Token{lex.Dollar, "$", 1, 1},
+			lex.Token{lex.LeftParen, "(", 2, 1},
+			lex.Token{lex.Name, "echo", 3, 1},
+			lex.Token{lex.Space, " ", 7, 1},
+			lex.Token{lex.DoubleQuote, `"`, 8, 1},
+			lex.Token{lex.StringSegment, ")", 9, 1},
+			lex.Token{lex.DoubleQuote, `"`, 10, 1},
+			lex.Token{lex.RightParen, ")", 11, 1},
+			lex.Token{lex.DoubleQuote, `"`, 12, 1},
+			lex.Token{lex.EOF, "", 13, 1},
+		},
+	},
+	lexData{
+		Input: "echo `echo \\`pwd\\``",
+		Tokens: []lex.Token{
+			lex.Token{lex.Name, "echo", 0, 1},
+			lex.Token{lex.Space, " ", 4, 1},
+			lex.Token{lex.BackquotedCommand, "echo `pwd`", 5, 1},
+			lex.Token{lex.EOF, "", 19, 1},
+		},
+	},
+	lexData{
+		Input: "$(echo",
+		Tokens: []lex.Token{
+			lex.Token{lex.Dollar, "$", 0, 1},
+			lex.Token{lex.LeftParen, "(", 1, 1},
+			lex.Token{lex.Name, "echo", 2, 1},
+			lex.Token{lex.ERROR, "Unclosed command substitution (expected ')')", 6, 1},
+		},
+	},
+	lexData{
+		Input: `$(($X * (2 + 1)))`,
+		Tokens: []lex.Token{
+			lex.Token{lex.Dollar, "$", 0, 1},
+			lex.Token{lex.DoubleLeftParen, "((", 1, 1},
+			lex.Token{lex.Dollar, "$", 3, 1},
+			lex.Token{lex.Name, "X", 4, 1},
+			lex.Token{lex.StringSegment, " * (", 5, 1},
+			lex.Token{lex.StringSegment, "2 + 1)", 9, 1},
+			lex.Token{lex.DoubleRightParen, "))", 15, 1},
+			lex.Token{lex.EOF, "", 17, 1},
+		},
+	},
+	lexData{
+		Input: "a) x;& [!a-c]*) y;;&",
+		Tokens: []lex.Token{
+			lex.Token{lex.Name, "a", 0, 1},
+			lex.Token{lex.RightParen, ")", 1, 1},
+			lex.Token{lex.Space, " ", 2, 1},
+			lex.Token{lex.Name, "x", 3, 1},
+			lex.Token{lex.SemiAnd, ";&", 4, 1},
+			lex.Token{lex.Space, " ", 6, 1},
+			lex.Token{lex.Name, "[!a-c]*", 7, 1},
+			lex.Token{lex.RightParen, ")", 14, 1},
+			lex.Token{lex.Space, " ", 15, 1},
+			lex.Token{lex.Name, "y", 16, 1},
+			lex.Token{lex.DoubleSemiAnd, ";;&", 17, 1},
+			lex.Token{lex.EOF, "", 20, 1},
+		},
+	},
+	lexData{
+		// an operator ends the word even inside a bracket expression
+		Input: "[a|b] [;]",
+		Tokens: []lex.Token{
+			lex.Token{lex.Name, "[a", 0, 1},
+			lex.Token{lex.Pipe, "|", 2, 1},
+			lex.Token{lex.Name, "b]", 3, 1},
+			lex.Token{lex.Space, " ", 5, 1},
+			lex.Token{lex.Name, "[", 6, 1},
+			lex.Token{lex.Semi, ";", 7, 1},
+			lex.Token{lex.Name, "]", 8, 1},
+			lex.Token{lex.EOF, "", 9, 1},
+		},
+	},
+	lexData{
+		Input: "$? ${?}",
+		Tokens: []lex.Token{
+			lex.Token{lex.Dollar, "$", 0, 1},
+			lex.Token{lex.SpecialParameter, "?", 1, 1},
+			lex.Token{lex.Space, " ", 2, 1},
+			lex.Token{lex.Dollar, "$", 3, 1},
+			lex.Token{lex.LeftBrace, "{", 4, 1},
+			lex.Token{lex.SpecialParameter, "?", 5, 1},
+			lex.Token{lex.RightBrace, "}", 6, 1},
+			lex.Token{lex.EOF, "", 7, 1},
+		},
+	},
+	lexData{
+		Input: "$10 ${10} $@ $#",
+		Tokens: []lex.Token{
+			lex.Token{lex.Dollar, "$", 0, 1},
+			lex.Token{lex.Number, "1", 1, 1},
+			lex.Token{lex.Number, "0", 2, 1},
+			lex.Token{lex.Space, " ", 3, 1},
+			lex.Token{lex.Dollar, "$", 4, 1},
+			lex.Token{lex.LeftBrace, "{", 5, 1},
+			lex.Token{lex.Number, "10", 6, 1},
+			lex.Token{lex.RightBrace, "}", 8, 1},
+			lex.Token{lex.Space, " ", 9, 1},
+			lex.Token{lex.Dollar, "$", 10, 1},
+			lex.Token{lex.SpecialParameter, "@", 11, 1},
+			lex.Token{lex.Space, " ", 12, 1},
+			lex.Token{lex.Dollar, "$", 13, 1},
+			lex.Token{lex.SpecialParameter, "#", 14, 1},
+			lex.Token{lex.EOF, "", 15, 1},
+		},
+	},
+	lexData{
+		Input: "$$ $! ${-} $_x",
+		Tokens: []lex.Token{
+			lex.Token{lex.Dollar, "$", 0, 1},
+			lex.Token{lex.SpecialParameter, "$", 1, 1},
+			lex.Token{lex.Space, " ", 2, 1},
+			lex.Token{lex.Dollar, "$", 3, 1},
+			lex.Token{lex.SpecialParameter, "!", 4, 1},
+			lex.Token{lex.Space, " ", 5, 1},
+			lex.Token{lex.Dollar, "$", 6, 1},
+			lex.Token{lex.LeftBrace, "{", 7, 1},
+			lex.Token{lex.SpecialParameter, "-", 8, 1},
+			lex.Token{lex.RightBrace, "}", 9, 1},
+			lex.Token{lex.Space, " ", 10, 1},
+			lex.Token{lex.Dollar, "$", 11, 1},
+			lex.Token{lex.Name, "_x", 12, 1},
+			lex.Token{lex.EOF, "", 14, 1},
+		},
+	},
 }
@@ -102,12 +102,23 @@ var PARSE_CASES = []parseData{
 	parseData{
 		Input: `"$MINI"`,
 		Output: ast.NewGenericNode(
-			&ast.Str{
-				Pieces: []ast.StrPiece{
-					&ast.ParameterExpansion{
-						VarName:  &lex.Token{lex.Name, "MINI", 2, 1},
-						Operator: nil,
-						Word:     nil,
+			&ast.CommandList{
+				Separators: []lex.Token{},
+				Commands: []ast.Command{
+					&ast.SimpleCommand{
+						Redirects: []*ast.IoRedirect{},
+						Words: []*ast.Str{
+							&ast.Str{
+								Pieces: []ast.StrPiece{
+									&ast.ParameterExpansion{
+										VarName:  &lex.Token{lex.Name, "MINI", 2, 1},
+										Operator: nil,
+										Word:     nil,
+										Quoted:   true,
+									},
+								},
+							},
+						},
 					},
 				},
 			},
@@ -117,12 +128,284 @@ var PARSE_CASES = []parseData{
 	parseData{
 		Input: `"${MINI}"`,
 		Output: ast.NewGenericNode(
-			&ast.Str{
-				Pieces: []ast.StrPiece{
-					&ast.ParameterExpansion{
-						VarName:  &lex.Token{lex.Name, "MINI", 3, 1},
-						Operator: nil,
-						Word:     nil,
+			&ast.CommandList{
+				Separators: []lex.Token{},
+				Commands: []ast.Command{
+					&ast.SimpleCommand{
+						Redirects: []*ast.IoRedirect{},
+						Words: []*ast.Str{
+							&ast.Str{
+								Pieces: []ast.StrPiece{
+									&ast.ParameterExpansion{
+										VarName:  &lex.Token{lex.Name, "MINI", 3, 1},
+										Operator: nil,
+										Word:     nil,
+										Quoted:   true,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		),
+		Error: nil,
+	},
+	parseData{
+		Input: "echo a | wc",
+		Output: ast.NewGenericNode(
+			&ast.CommandList{
+				Separators: []lex.Token{},
+				Commands: []ast.Command{
+					&ast.Pipeline{
+						Commands: []ast.Command{
+							&ast.SimpleCommand{
+								Redirects: []*ast.IoRedirect{},
+								Words: []*ast.Str{
+									ast.NewStrFromTok(lex.Token{lex.Name, "echo", 0, 1}),
+									ast.NewStrFromTok(lex.Token{lex.Name, "a", 5, 1}),
+								},
+							},
+							&ast.SimpleCommand{
+								Redirects: []*ast.IoRedirect{},
+								Words: []*ast.Str{
+									ast.NewStrFromTok(lex.Token{lex.Name, "wc", 9, 1}),
+								},
+							},
+						},
+					},
+				},
+			},
+		),
+		Error: nil,
+	},
+	parseData{
+		Input: "if a; then b; fi",
+		Output: ast.NewGenericNode(
+			&ast.CommandList{
+				Separators: []lex.Token{},
+				Commands: []ast.Command{
+					&ast.IfClause{
+						Condition: []ast.Node{
+							&ast.CommandList{
+								Separators: []lex.Token{lex.Token{lex.Semi, ";", 4, 1}},
+								Commands: []ast.Command{
+									&ast.SimpleCommand{
+										Redirects: []*ast.IoRedirect{},
+										Words: []*ast.Str{
+											ast.NewStrFromTok(lex.Token{lex.Name, "a", 3, 1}),
+										},
+									},
+								},
+							},
+						},
+						Body: []ast.Node{
+							&ast.CommandList{
+								Separators: []lex.Token{lex.Token{lex.Semi, ";", 12, 1}},
+								Commands: []ast.Command{
+									&ast.SimpleCommand{
+										Redirects: []*ast.IoRedirect{},
+										Words: []*ast.Str{
+											ast.NewStrFromTok(lex.Token{lex.Name, "b", 11, 1}),
+										},
+									},
+								},
+							},
+						},
+						Else:      nil,
+						Redirects: []*ast.IoRedirect{},
+					},
+				},
+			},
+		),
+		Error: nil,
+	},
+	parseData{
+		Input: "case x in (a|b) c;& *) esac",
+		Output: ast.NewGenericNode(
+			&ast.CommandList{
+				Separators: []lex.Token{},
+				Commands: []ast.Command{
+					&ast.CaseClause{
+						Word: ast.NewStrFromTok(lex.Token{lex.Name, "x", 5, 1}),
+						Items: []*ast.CaseItem{
+							&ast.CaseItem{
+								Patterns: []*ast.Str{
+									ast.NewStrFromTok(lex.Token{lex.Name, "a", 11, 1}),
+									ast.NewStrFromTok(lex.Token{lex.Name, "b", 13, 1}),
+								},
+								Body: []ast.Node{
+									&ast.CommandList{
+										Separators: []lex.Token{},
+										Commands: []ast.Command{
+											&ast.SimpleCommand{
+												Redirects: []*ast.IoRedirect{},
+												Words: []*ast.Str{
+													ast.NewStrFromTok(lex.Token{lex.Name, "c", 16, 1}),
+												},
+											},
+										},
+									},
+								},
+								Terminator: lex.SemiAnd,
+							},
+							&ast.CaseItem{
+								Patterns: []*ast.Str{
+									ast.NewStrFromTok(lex.Token{lex.Name, "*", 20, 1}),
+								},
+								Body:       []ast.Node{},
+								Terminator: lex.DoubleSemi,
+							},
+						},
+						Redirects: []*ast.IoRedirect{},
+					},
+				},
+			},
+		),
+		Error: nil,
+	},
+	parseData{
+		Input: "a && b || c; d",
+		Output: ast.NewGenericNode(
+			&ast.CommandList{
+				Separators: []lex.Token{lex.Token{lex.Semi, ";", 11, 1}},
+				Commands: []ast.Command{
+					&ast.AndOrClause{
+						Left: &ast.SimpleCommand{
+							Redirects: []*ast.IoRedirect{},
+							Words: []*ast.Str{
+								ast.NewStrFromTok(lex.Token{lex.Name, "a", 0, 1}),
+							},
+						},
+						Operator: &lex.Token{lex.AndIf, "&&", 2, 1},
+						AndOrClause: &ast.AndOrClause{
+							Left: &ast.SimpleCommand{
+								Redirects: []*ast.IoRedirect{},
+								Words: []*ast.Str{
+									ast.NewStrFromTok(lex.Token{lex.Name, "b", 5, 1}),
+								},
+							},
+							Operator: &lex.Token{lex.OrIf, "||", 7, 1},
+							AndOrClause: &ast.AndOrClause{
+								Left: &ast.SimpleCommand{
+									Redirects: []*ast.IoRedirect{},
+									Words: []*ast.Str{
+										ast.NewStrFromTok(lex.Token{lex.Name, "c", 10, 1}),
+									},
+								},
+							},
+						},
+					},
+					&ast.SimpleCommand{
+						Redirects: []*ast.IoRedirect{},
+						Words: []*ast.Str{
+							ast.NewStrFromTok(lex.Token{lex.Name, "d", 13, 1}),
+						},
+					},
+				},
+			},
+		),
+		Error: nil,
+	},
+	parseData{
+		Input: "! a | b",
+		Output: ast.NewGenericNode(
+			&ast.CommandList{
+				Separators: []lex.Token{},
+				Commands: []ast.Command{
+					&ast.Pipeline{
+						Commands: []ast.Command{
+							&ast.SimpleCommand{
+								Redirects: []*ast.IoRedirect{},
+								Words: []*ast.Str{
+									ast.NewStrFromTok(lex.Token{lex.Name, "a", 2, 1}),
+								},
+							},
+							&ast.SimpleCommand{
+								Redirects: []*ast.IoRedirect{},
+								Words: []*ast.Str{
+									ast.NewStrFromTok(lex.Token{lex.Name, "b", 6, 1}),
+								},
+							},
+						},
+						Negated: true,
+					},
+				},
+			},
+		),
+		Error: nil,
+	},
+	parseData{
+		Input: "A=1 echo B=2",
+		Output: ast.NewGenericNode(
+			&ast.CommandList{
+				Separators: []lex.Token{},
+				Commands: []ast.Command{
+					&ast.SimpleCommand{
+						Assignments: []*ast.Assignment{
+							&ast.Assignment{
+								Name:  "A",
+								Value: &ast.Str{Pieces: []ast.StrPiece{ast.RawStr("1")}},
+							},
+						},
+						Redirects: []*ast.IoRedirect{},
+						Words: []*ast.Str{
+							ast.NewStrFromTok(lex.Token{lex.Name, "echo", 4, 1}),
+							ast.NewStrFromTok(lex.Token{lex.AssignmentWord, "B=2", 9, 1}),
+						},
+					},
+				},
+			},
+		),
+		Error: nil,
+	},
+	parseData{
+		Input: "echo 'a'\"b\"c ${X}.txt",
+		Output: ast.NewGenericNode(
+			&ast.CommandList{
+				Separators: []lex.Token{},
+				Commands: []ast.Command{
+					&ast.SimpleCommand{
+						Redirects: []*ast.IoRedirect{},
+						Words: []*ast.Str{
+							ast.NewStrFromTok(lex.Token{lex.Name, "echo", 0, 1}),
+							&ast.Str{
+								Pieces: []ast.StrPiece{
+									ast.QuotedStr("a"),
+									ast.QuotedStr("b"),
+									ast.RawStr("c"),
+								},
+							},
+							&ast.Str{
+								Pieces: []ast.StrPiece{
+									&ast.ParameterExpansion{
+										VarName:  &lex.Token{lex.Name, "X", 15, 1},
+										Operator: nil,
+										Word:     nil,
+									},
+									ast.RawStr(".txt"),
+								},
+							},
+						},
+					},
+				},
+			},
+		),
+		Error: nil,
+	},
+	parseData{
+		Input: "echo done in",
+		Output: ast.NewGenericNode(
+			&ast.CommandList{
+				Separators: []lex.Token{},
+				Commands: []ast.Command{
+					&ast.SimpleCommand{
+						Redirects: []*ast.IoRedirect{},
+						Words: []*ast.Str{
+							ast.NewStrFromTok(lex.Token{lex.Name, "echo", 0, 1}),
+							ast.NewStrFromTok(lex.Token{lex.Name, "done", 5, 1}),
+							ast.NewStrFromTok(lex.Token{lex.Name, "in", 10, 1}),
+						},
 					},
 				},
 			},
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/pglass/pshhh/exe"
+	"github.com/stretchr/testify/assert"
+)
+
+type patternData struct {
+	Pattern string
+	Text    string
+	Match   bool
+}
+
+func TestMatchPattern(t *testing.T) {
+	for _, data := range PATTERN_CASES {
+		t.Run(data.Pattern+" "+data.Text, func(t *testing.T) {
+			assert.Equal(t, data.Match, exe.MatchPattern(data.Pattern, data.Text))
+		})
+	}
+}
+
+var PATTERN_CASES = []patternData{
+	patternData{"abc", "abc", true},
+	patternData{"abc", "abd", false},
+	patternData{"", "", true},
+	patternData{"*", "", true},
+	patternData{"*", "anything", true},
+	patternData{"a*", "abc", true},
+	patternData{"*c", "abc", true},
+	patternData{"a*c", "ac", true},
+	patternData{"a*b*c", "axxbyyc", true},
+	patternData{"a*b*c", "axxbyy", false},
+	patternData{"*.txt", "notes.txt.bak", false},
+	patternData{"?", "a", true},
+	patternData{"?", "", false},
+	patternData{"a?c", "abc", true},
+	patternData{"[abc]", "b", true},
+	patternData{"[abc]", "d", false},
+	patternData{"[a-c]x", "bx", true},
+	patternData{"[!a-c]", "b", false},
+	patternData{"[^a-c]", "d", true},
+	patternData{"[]a]", "]", true},
+	patternData{"[a-]", "-", true},
+	patternData{"[[:digit:]][[:alpha:]]", "1a", true},
+	patternData{"[[:upper:]]", "a", false},
+	patternData{"[abc", "[abc", true},
+	patternData{`\*`, "*", true},
+	patternData{`\*`, "a", false},
+	patternData{`a\?`, "ab", false},
+}